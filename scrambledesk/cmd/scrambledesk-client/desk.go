@@ -0,0 +1,380 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/larsjohanfolde/scrambleman/scrambledesk/config"
+	"github.com/larsjohanfolde/scrambleman/scrambledesk/internal/models"
+	"github.com/larsjohanfolde/scrambleman/scrambledesk/internal/upload"
+)
+
+// confirmTimeout is how long an interactive prompt waits before declining
+const confirmTimeout = 2 * time.Minute
+
+// desk runs actions on the competition for the interactive front ends. Only one
+// action runs at a time and the state is saved after every successful action.
+// A failed action is rolled back to the saved state.
+type desk struct {
+	mu       sync.Mutex // guards comp while an action runs
+	comp     *models.Competition
+	confirm  *models.RemoteConfirmer
+	maxEarly time.Duration
+
+	statusMu   sync.Mutex
+	busy       string
+	message    string
+	failed     bool
+	connection error
+	checked    time.Time
+	last       overview
+	notes      []string
+	changed    chan struct{}
+}
+
+// maxNotes is the number of messages from the models package kept for the front ends
+const maxNotes = 3
+
+// overview is what the front ends show of the competition
+type overview struct {
+	Name          string
+	Rooms         []string
+	Current       string
+	CurrentOpened string
+	Next          string
+	NextPlanned   string
+	Rows          []scheduleRow
+}
+
+// scheduleRow is a round, or one of its groups, with planned and actual times
+type scheduleRow struct {
+	Name    string
+	Group   bool
+	Attempt bool
+	Planned string
+	Actual  string
+	State   string
+}
+
+func newDesk(maxEarly time.Duration) (*desk, error) {
+	comp, err := loadCompetition()
+	if err != nil {
+		return nil, err
+	}
+	comp.SortRounds()
+
+	// Ask for the passphrase now rather than in the middle of an action
+	err = comp.Unlock()
+	if err != nil {
+		return nil, err
+	}
+
+	return &desk{
+		comp:     comp,
+		confirm:  models.NewRemoteConfirmer(confirmTimeout),
+		maxEarly: maxEarly,
+		changed:  make(chan struct{}, 1),
+	}, nil
+}
+
+// run starts the action in the background. Returns false if another action is still running.
+func (d *desk) run(name string, action func(c *models.Competition) (string, error)) bool {
+	d.statusMu.Lock()
+	if d.busy != "" {
+		d.statusMu.Unlock()
+		return false
+	}
+	d.busy = name
+	d.message = ""
+	d.statusMu.Unlock()
+	d.notify()
+
+	go func() {
+		d.mu.Lock()
+		message, err := action(d.comp)
+		if err == nil {
+			err = d.comp.Save()
+		} else if reloadErr := d.comp.Reload(); reloadErr != nil {
+			err = fmt.Errorf("%w, and could not reload the saved state: %v", err, reloadErr)
+		} else {
+			d.comp.SortRounds()
+		}
+		d.mu.Unlock()
+
+		d.statusMu.Lock()
+		d.busy = ""
+		d.failed = err != nil && !errors.Is(err, models.ErrCancelled)
+		switch {
+		case errors.Is(err, models.ErrCancelled):
+			d.message = fmt.Sprintf("%s cancelled", name)
+		case err != nil:
+			d.message = fmt.Sprintf("%s failed: %v", name, err)
+		default:
+			d.message = message
+		}
+		d.statusMu.Unlock()
+		d.notify()
+	}()
+	return true
+}
+
+// view calls fn with the competition unless an action is modifying it
+func (d *desk) view(fn func(c *models.Competition)) bool {
+	if !d.mu.TryLock() {
+		return false
+	}
+	defer d.mu.Unlock()
+	fn(d.comp)
+	return true
+}
+
+// overview describes the competition. While an action is running the last known state is returned.
+func (d *desk) overview() overview {
+	var o overview
+	ok := d.view(func(c *models.Competition) {
+		o = buildOverview(c)
+	})
+
+	d.statusMu.Lock()
+	defer d.statusMu.Unlock()
+	if !ok {
+		return d.last
+	}
+	d.last = o
+	return o
+}
+
+func buildOverview(c *models.Competition) overview {
+	o := overview{Name: c.Name}
+	for _, id := range c.SelectedRooms {
+		if r := c.Room(id); r != nil {
+			o.Rooms = append(o.Rooms, r.Name)
+		}
+	}
+	if len(o.Rooms) == 0 {
+		o.Rooms = append(o.Rooms, "All rooms")
+	}
+
+	current := c.CurrentGroup()
+	if current != nil {
+		o.Current = current.EventName
+		o.CurrentOpened = clock(current.OpenedAt())
+	}
+	next, nextAttempt := c.Next()
+	if next != nil {
+		o.Next = slotName(next, nextAttempt)
+		o.NextPlanned = clock(next.StartTime)
+		if nextAttempt != nil {
+			o.NextPlanned = clock(nextAttempt.StartTime)
+		}
+	}
+
+	for _, r := range c.Rounds {
+		if len(c.SelectedRooms) > 0 && !slices.Contains(c.SelectedRooms, r.RoomID) {
+			continue
+		}
+
+		row := scheduleRow{Name: r.EventName, Planned: timeRange(r.StartTime, r.EndTime)}
+		if r.Done() {
+			row.State = "done"
+		}
+		o.Rows = append(o.Rows, row)
+
+		for _, g := range r.Groups {
+			row := scheduleRow{
+				Name:    fmt.Sprintf("Group %d", g.GroupNumber),
+				Group:   true,
+				Planned: timeRange(g.StartTime, g.EndTime),
+				State:   string(g.State),
+			}
+			row.Actual = actualRange(g.OpenedAt(), g.ClosedAt())
+			if next != nil && nextAttempt == nil && g.ActivityCode == next.ActivityCode {
+				row.State = "next"
+			}
+			o.Rows = append(o.Rows, row)
+
+			for _, a := range g.Attempts {
+				row := scheduleRow{
+					Name:    fmt.Sprintf("Attempt %d", a.Number),
+					Group:   true,
+					Attempt: true,
+					Planned: timeRange(a.StartTime, a.EndTime),
+					Actual:  actualRange(a.OpenedAt(), a.ClosedAt()),
+					State:   string(a.State),
+				}
+				if nextAttempt != nil && a.ActivityCode == nextAttempt.ActivityCode {
+					row.State = "next"
+				}
+				o.Rows = append(o.Rows, row)
+			}
+		}
+	}
+	return o
+}
+
+func clock(t time.Time) string {
+	if t.IsZero() {
+		return "--:--"
+	}
+	return t.Local().Format("15:04")
+}
+
+func actualRange(opened, closed time.Time) string {
+	if opened.IsZero() {
+		return ""
+	}
+	if closed.IsZero() {
+		return clock(opened) + "-"
+	}
+	return clock(opened) + "-" + clock(closed)
+}
+
+func timeRange(start, end time.Time) string {
+	if start.IsZero() {
+		return ""
+	}
+	return clock(start) + "-" + clock(end)
+}
+
+func (d *desk) status() (busy, message string, failed bool) {
+	d.statusMu.Lock()
+	defer d.statusMu.Unlock()
+	return d.busy, d.message, d.failed
+}
+
+// watchDisplay checks the connection to the display until stop is closed
+func (d *desk) watchDisplay(interval time.Duration, stop <-chan struct{}) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		err := upload.Ping(config.IP, 3*time.Second)
+		d.statusMu.Lock()
+		d.connection = err
+		d.checked = time.Now()
+		d.statusMu.Unlock()
+		d.notify()
+
+		select {
+		case <-ticker.C:
+		case <-stop:
+			return
+		}
+	}
+}
+
+func (d *desk) displayStatus() (time.Time, error) {
+	d.statusMu.Lock()
+	defer d.statusMu.Unlock()
+	return d.checked, d.connection
+}
+
+// Write keeps the messages the models package logs, so the front ends can show them
+func (d *desk) Write(p []byte) (int, error) {
+	d.statusMu.Lock()
+	for _, line := range strings.Split(strings.TrimSpace(string(p)), "\n") {
+		if line = strings.TrimSpace(line); line != "" {
+			d.notes = append(d.notes, line)
+		}
+	}
+	d.notes = d.notes[max(len(d.notes)-maxNotes, 0):]
+	d.statusMu.Unlock()
+	d.notify()
+	return len(p), nil
+}
+
+func (d *desk) recentNotes() []string {
+	d.statusMu.Lock()
+	defer d.statusMu.Unlock()
+	return slices.Clone(d.notes)
+}
+
+func (d *desk) notify() {
+	select {
+	case d.changed <- struct{}{}:
+	default:
+	}
+}
+
+// The actions shared by the front ends
+
+func (d *desk) next() bool {
+	return d.run("Next group", func(c *models.Competition) (string, error) {
+		err := c.StartNextGroup(d.confirm, d.maxEarly)
+		if err != nil {
+			return "", err
+		}
+		return fmt.Sprintf("Opened %s", c.CurrentGroup().EventName), nil
+	})
+}
+
+func (d *desk) handIn() bool {
+	return d.run("Hand-in", func(c *models.Competition) (string, error) {
+		group := c.OpenGroup()
+		if group == nil {
+			return "", models.ErrNoOpenGroup
+		}
+		err := d.ask(fmt.Sprintf("Close the scrambles of %s and open hand-in?", group.EventName))
+		if err != nil {
+			return "", err
+		}
+
+		err = c.OpenHandIn()
+		if err != nil {
+			return "", err
+		}
+		return fmt.Sprintf("Closed the scrambles of %s", group.EventName), nil
+	})
+}
+
+func (d *desk) reopen() bool {
+	return d.run("Reopen", func(c *models.Competition) (string, error) {
+		group := c.CurrentGroup()
+		if group == nil {
+			return "", errors.New("no group has been opened yet")
+		}
+		err := d.ask(fmt.Sprintf("Reopen %s?", group.CurrentScrambleSet()))
+		if err != nil {
+			return "", err
+		}
+
+		err = c.OpenScrambleSet(group.ActivityCode)
+		if err != nil {
+			return "", err
+		}
+		return fmt.Sprintf("Reopened %s", group.CurrentScrambleSet()), nil
+	})
+}
+
+func (d *desk) undo() bool {
+	return d.run("Undo", func(c *models.Competition) (string, error) {
+		if len(c.Operations) == 0 {
+			return "", models.ErrNothingToUndo
+		}
+		last := c.Operations[len(c.Operations)-1]
+		err := d.ask(fmt.Sprintf("Undo %s from %s?", last.Kind, last.Time.Local().Format("15:04:05")))
+		if err != nil {
+			return "", err
+		}
+
+		op, err := c.Undo()
+		if err != nil {
+			return "", err
+		}
+		return fmt.Sprintf("Reverted %s", op.Kind), nil
+	})
+}
+
+func (d *desk) ask(prompt string) error {
+	yes, err := d.confirm.Confirm(prompt)
+	if err != nil {
+		return err
+	}
+	if !yes {
+		return models.ErrCancelled
+	}
+	return nil
+}
@@ -1,36 +1,130 @@
 package main
 
 import (
+	"errors"
 	"flag"
 	"fmt"
 	"log"
+	"net/url"
 	"os"
-	"os/exec"
+	"slices"
+	"strings"
 	"time"
 
 	"github.com/larsjohanfolde/scrambleman/scrambledesk/config"
+	"github.com/larsjohanfolde/scrambleman/scrambledesk/internal/audit"
 	"github.com/larsjohanfolde/scrambleman/scrambledesk/internal/models"
+	"github.com/larsjohanfolde/scrambleman/scrambledesk/internal/pdf"
 )
 
 func main() {
+	models.Passphrase = readPassphrase
+
+	// Interactive front ends are subcommands with their own flags
+	if len(os.Args) > 1 {
+		var run func(args []string) error
+		switch os.Args[1] {
+		case "tui":
+			run = runTUI
+		case "web":
+			run = runWeb
+		}
+		if run != nil {
+			err := run(os.Args[2:])
+			if err != nil {
+				fmt.Println(err)
+				os.Exit(1)
+			}
+			return
+		}
+	}
+
 	next := flag.Bool("n", false, "Open the next scramble file")
 	close := flag.Bool("c", false, "Close the active scramble set")
+	previous := flag.Bool("previous", false, "Undo the last group transition")
 	persons := flag.Bool("reload-competitors", false, "Reload the registered competitors")
 	openScrambleSet := flag.String("o", "", "Open a spesific scramble set")
-	startFrom := flag.String("start-from", "", "Mark all previous rounds as finished and start from the inputted round")
+	startFrom := flag.String("start-from", "", "Skip all previous groups and start from the inputted group")
 	ip := flag.String("ip", "", "Define the server IP and store this for future use")
+	rooms := flag.String("rooms", "", "Select the room(s) this desk manages, by ID or name, comma separated, \"all\" selects every room")
+	listRooms := flag.Bool("list-rooms", false, "List the rooms of the competition")
 	competitionId := flag.String("init", "", "Load a competition ID")
+	wcifFile := flag.String("init-file", "", "Load a competition from a local WCIF file")
+	importScrambles := flag.String("import-scrambles", "", "Import the scramble sets from the zip generated by TNoodle")
+	apiURL := flag.String("api-url", "", "Define the WCA API base URL and store this for future use, \"default\" resets it")
 	export := flag.Bool("export", false, "Export the competition data to a json file")
+	restore := flag.String("restore", "", "Restore a backed up state by number or name, \"list\" shows the backups")
 	debug := flag.Bool("debug", false, "Debug")
+	status := flag.Bool("status", false, "Show how far ahead of or behind schedule the competition is")
+	check := flag.Bool("check", false, "Check that the scramble sets, passwords, display files and display connection are ready for the competition")
+	watermark := flag.String("watermark", "", "Turn the watermark on the scramble sets \"on\" or \"off\" for this competition")
+	watermarkStyle := flag.String("watermark-style", "", "Set the pdfcpu description of the watermark, \"default\" resets it")
+	verifyAudit := flag.Bool("verify-audit", false, "Verify that the audit log of scramble set access has not been tampered with")
+	yes := flag.Bool("yes", false, "Answer yes to every confirmation, groups more than -max-early ahead of schedule are refused")
+	maxEarly := flag.Duration("max-early", models.DefaultMaxEarly, "How long before its planned start a group can be opened without an extra confirmation")
 	flag.Parse()
 
+	if *apiURL == "default" {
+		err := os.Remove(config.APIURLFile)
+		if err != nil && !os.IsNotExist(err) {
+			log.Fatalf("Could not reset API URL: %v", err)
+		}
+		config.APIBaseURL = config.DefaultAPIBaseURL
+	} else if *apiURL != "" {
+		u, err := url.Parse(*apiURL)
+		if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+			log.Fatalf("Invalid API URL %q, expected something like %s", *apiURL, config.DefaultAPIBaseURL)
+		}
+
+		err = os.WriteFile(config.APIURLFile, []byte(*apiURL), 0644)
+		if err != nil {
+			log.Fatalf("Could not save API URL to file: %v", err)
+		}
+		config.APIBaseURL = strings.TrimRight(*apiURL, "/")
+	}
+
 	if *persons {
 		comp, err := loadCompetition()
 		if err != nil {
 			log.Fatal(err)
 		}
 
-		comp.Save()
+		err = comp.ReloadPersons()
+		if err != nil {
+			log.Fatalf("Could not reload competitors: %v", err)
+		}
+
+		err = comp.Save()
+		if err != nil {
+			log.Fatal(err)
+		}
+	}
+
+	if *restore != "" {
+		id, err := models.SavedCompetitionID()
+		if err != nil {
+			log.Fatalf("Could not read the current competition: %v", err)
+		}
+
+		if *restore == "list" {
+			backups, err := models.ListBackups(id)
+			if err != nil {
+				log.Fatalf("Could not list backups: %v", err)
+			}
+			if len(backups) == 0 {
+				fmt.Printf("No backups of %s\n", id)
+			}
+
+			for i, b := range backups {
+				fmt.Printf("%d: %s (%s)\n", i+1, b.Name, b.Time.Format("2006-01-02 15:04:05"))
+			}
+		} else {
+			_, err = models.RestoreBackup(id, *restore)
+			if err != nil {
+				log.Fatalf("Could not restore backup: %v", err)
+			}
+			fmt.Printf("Restored %s\n", *restore)
+		}
 	}
 
 	if *ip != "" {
@@ -40,6 +134,83 @@ func main() {
 		}
 	}
 
+	if *rooms != "" {
+		comp, err := loadCompetition()
+		if err != nil {
+			log.Fatal(err)
+		}
+
+		err = comp.SelectRooms(strings.Split(*rooms, ","))
+		if err != nil {
+			log.Fatalf("Could not select rooms: %v", err)
+		}
+
+		err = comp.Save()
+		if err != nil {
+			log.Fatal(err)
+		}
+		if len(comp.SelectedRooms) == 0 {
+			fmt.Println("Managing every room")
+		}
+	}
+
+	if *watermark != "" || *watermarkStyle != "" {
+		comp, err := loadCompetition()
+		if err != nil {
+			log.Fatal(err)
+		}
+
+		switch *watermark {
+		case "":
+		case "on":
+			comp.Watermark.Disabled = false
+		case "off":
+			comp.Watermark.Disabled = true
+		default:
+			log.Fatalf("Unknown watermark setting %q, use \"on\" or \"off\"", *watermark)
+		}
+
+		if *watermarkStyle == "default" {
+			comp.Watermark.Style = ""
+		} else if *watermarkStyle != "" {
+			err = pdf.ValidateWatermark(*watermarkStyle)
+			if err != nil {
+				log.Fatal(err)
+			}
+			comp.Watermark.Style = *watermarkStyle
+		}
+
+		err = comp.Save()
+		if err != nil {
+			log.Fatal(err)
+		}
+
+		style := comp.Watermark.Style
+		if style == "" {
+			style = models.DefaultWatermarkStyle
+		}
+		if comp.Watermark.Disabled {
+			fmt.Println("Scramble sets are sent without a watermark")
+		} else {
+			fmt.Printf("Scramble sets are watermarked with: %s\n", style)
+		}
+	}
+
+	if *listRooms {
+		comp, err := loadCompetition()
+		if err != nil {
+			log.Fatal(err)
+		}
+
+		for _, r := range comp.Rooms {
+			selected := ""
+			if len(comp.SelectedRooms) == 0 || slices.Contains(comp.SelectedRooms, r.ID) {
+				selected = " [Selected]"
+			}
+			fmt.Printf("%d: %s%s\n", r.ID, r, selected)
+		}
+	}
+
 	if *debug {
 		comp, err := loadCompetition()
 		if err != nil {
@@ -48,13 +219,58 @@ func main() {
 		comp.SortRounds()
 
 		for _, r := range comp.Rounds {
-			fmt.Printf("%s: RoundNumber (%d), GroupCount (%d) [Finished = %t]\n", r.EventName, r.RoundNumber, r.GroupCount, r.Finished)
+			room := "Unknown room"
+			if rm := comp.Room(r.RoomID); rm != nil {
+				room = rm.String()
+			}
+			fmt.Printf("%s: RoundNumber (%d), GroupCount (%d), Room (%s) [Done = %t]\n", r.EventName, r.RoundNumber, r.GroupCount, room, r.Done())
+			if r.GroupSource != "" {
+				fmt.Printf("\tGroups from: %s\n", r.GroupSource)
+			}
 			for _, g := range r.Groups {
-				fmt.Printf("\t%s: GroupNumber (%d) [Finished = %t]\n", g.EventName, g.GroupNumber, g.Finished)
+				fmt.Printf("\t%s: GroupNumber (%d) [State = %s]\n", g.EventName, g.GroupNumber, g.State)
 			}
 		}
 	}
 
+	if *status {
+		comp, err := loadCompetition()
+		if err != nil {
+			log.Fatal(err)
+		}
+
+		printStatus(comp.ScheduleStatus(time.Now()))
+	}
+
+	if *check {
+		comp, err := loadCompetition()
+		if err != nil {
+			log.Fatal(err)
+		}
+		comp.SortRounds()
+
+		if !printReadiness(comp.Readiness()) {
+			os.Exit(1)
+		}
+	}
+
+	if *verifyAudit {
+		// The competition keeps the last entry it recorded, which the log must still hold
+		var anchor audit.Anchor
+		if comp, err := models.LoadCompetitionFromFile(fmt.Sprintf("%s/competition.json", config.AppDataDir)); err == nil {
+			anchor = comp.AuditAnchor
+		}
+		count, err := audit.Verify(config.AuditFile, anchor)
+		if errors.Is(err, os.ErrNotExist) {
+			fmt.Println("The audit log is empty")
+		} else if err != nil {
+			fmt.Printf("The audit log is broken after %d intact entries: %v\n", count, err)
+			os.Exit(1)
+		} else {
+			fmt.Printf("The audit log is intact, %d entries\n", count)
+		}
+	}
+
 	if *openScrambleSet != "" {
 		comp, err := loadCompetition()
 		if err != nil {
@@ -66,7 +282,10 @@ func main() {
 			log.Fatalf("Could not open scramble set: %v\n", err)
 		}
 
-		comp.Save()
+		err = comp.Save()
+		if err != nil {
+			log.Fatal(err)
+		}
 	}
 
 	if *startFrom != "" {
@@ -74,9 +293,15 @@ func main() {
 		if err != nil {
 			log.Fatal(err)
 		}
-		comp.StartFrom(*startFrom)
+		err = comp.StartFrom(*startFrom)
+		if err != nil {
+			log.Fatalf("Could not start from %s: %v", *startFrom, err)
+		}
 
-		comp.Save()
+		err = comp.Save()
+		if err != nil {
+			log.Fatal(err)
+		}
 	}
 
 	if *competitionId != "" {
@@ -85,20 +310,39 @@ func main() {
 			log.Fatal(err)
 		}
 
-		comp, err := models.BuildCompetitionFromWCIF(data)
+		initCompetition(data)
+	}
+
+	if *wcifFile != "" {
+		data, err := os.ReadFile(*wcifFile)
 		if err != nil {
-			log.Fatal(err)
+			log.Fatalf("Could not read WCIF file: %v", err)
 		}
 
-		err = comp.LoadAvatars()
+		initCompetition(data)
+	}
+
+	if *importScrambles != "" {
+		comp, err := loadCompetition()
 		if err != nil {
-			fmt.Printf("Could not load avatar: %v\n", err)
+			log.Fatal(err)
+		}
+
+		count, err := comp.ImportScrambles(*importScrambles)
+		if errors.Is(err, models.ErrZipEncrypted) {
+			log.Fatalf("Could not import scramble sets: %v, extract it with the password and zip the contents again", err)
+		} else if err != nil {
+			log.Fatalf("Could not import scramble sets: %v", err)
 		}
 
 		err = comp.Save()
 		if err != nil {
 			log.Fatal(err)
 		}
+		fmt.Printf("Imported %d scramble sets into %s\n", count, comp.ScrambleDir())
+		for _, p := range append(comp.ScrambleProblems(), comp.Passcodes.Problems()...) {
+			fmt.Printf("Warning: %v\n", p)
+		}
 	}
 
 	if *close {
@@ -106,43 +350,35 @@ func main() {
 		if err != nil {
 			log.Fatal(err)
 		}
-		group := comp.NextGroup()
-		// Ensure we have the competitors for the advanced rounds
-		if group.RoundNumber > 1 {
-			comp.AssignAdvancedRoundCompetitors()
-		}
-		group.DrawHandInPDF()
 
-		f := fmt.Sprintf("file=@%s/templates/intermission.pdf", config.AppDataDir)
-		cmd := exec.Command(
-			"curl",
-			"--cert", config.ClientCrt,
-			"--key", config.ClientKey,
-			"--cacert", config.CaCrt,
-			"-F", f,
-			config.ScrambleURL,
-		)
+		err = comp.OpenHandIn()
+		if err != nil {
+			log.Fatalf("Could not open hand-in: %v", err)
+		}
 
-		_, err = cmd.CombinedOutput()
+		err = comp.Save()
 		if err != nil {
 			log.Fatal(err)
 		}
+	}
 
-		group.ClosedTimestamp = append(group.ClosedTimestamp, time.Now())
+	if *previous {
+		comp, err := loadCompetition()
+		if err != nil {
+			log.Fatal(err)
+		}
+		comp.SortRounds()
 
-		cmd = exec.Command(
-			"curl",
-			"--cert", config.ClientCrt,
-			"--key", config.ClientKey,
-			"--cacert", config.CaCrt,
-			"-F", "file=@profiles.pdf",
-			config.CompetitorListURL,
-		)
+		op, err := comp.Undo()
+		if err != nil {
+			log.Fatalf("Could not undo: %v", err)
+		}
 
-		_, err = cmd.CombinedOutput()
+		err = comp.Save()
 		if err != nil {
 			log.Fatal(err)
 		}
+		fmt.Printf("Reverted %s from %s\n", op.Kind, op.Time.Format("15:04:05"))
 	}
 
 	if *next {
@@ -152,8 +388,25 @@ func main() {
 		}
 		comp.SortRounds()
 
-		comp.StartNextGroup()
-		comp.Save()
+		var confirm models.Confirmer = models.NewStdinConfirmer()
+		if *yes {
+			confirm = models.AlwaysYes{}
+		}
+
+		err = comp.StartNextGroup(confirm, *maxEarly)
+		if errors.Is(err, models.ErrCancelled) {
+			os.Exit(0)
+		}
+		if errors.Is(err, models.ErrNoUpcomingGroup) {
+			fmt.Println("No new rounds")
+		} else if err != nil {
+			log.Fatalf("Could not start next group: %v", err)
+		}
+
+		err = comp.Save()
+		if err != nil {
+			log.Fatal(err)
+		}
 	}
 
 	if *export {
@@ -169,6 +422,30 @@ func main() {
 	}
 }
 
+func initCompetition(data []byte) {
+	comp, err := models.BuildCompetitionFromWCIF(data)
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	err = comp.LoadAvatars()
+	if err != nil {
+		fmt.Printf("Could not load avatar: %v\n", err)
+	}
+
+	err = comp.Save()
+	if err != nil {
+		log.Fatal(err)
+	}
+	for _, p := range comp.Passcodes.Problems() {
+		fmt.Printf("Warning: %v\n", p)
+	}
+
+	if len(comp.Rooms) > 1 {
+		fmt.Printf("The competition has %d rooms, this desk manages all of them. Use -list-rooms and -rooms to manage only some.\n", len(comp.Rooms))
+	}
+}
+
 func loadCompetition() (*models.Competition, error) {
 	saveLocation := fmt.Sprintf("%s/competition.json", config.AppDataDir)
 	comp, err := models.LoadCompetitionFromFile(saveLocation)
@@ -176,5 +453,107 @@ func loadCompetition() (*models.Competition, error) {
 		return nil, err
 	}
 
+	if comp.HasPlaintextPasswords() {
+		fmt.Println("Sealing the scramble passwords saved in plaintext by an older version")
+		err = comp.Save()
+		if err != nil {
+			return nil, err
+		}
+	}
 	return comp, nil
 }
+
+func printStatus(s models.ScheduleStatus) {
+	fmt.Printf("Schedule for %s at %s\n\n", s.Day.Format("Monday 2 January"), s.Time.Format("15:04"))
+
+	if s.Current != nil {
+		opened, planned := s.Current.OpenedAt(), s.Current.StartTime
+		if a := s.CurrentAttempt; a != nil {
+			opened, planned = a.OpenedAt(), a.StartTime
+		}
+		fmt.Printf("Current: %s, opened %s (planned %s)\n", slotName(s.Current, s.CurrentAttempt), clock(opened), clock(planned))
+	}
+	if s.Next != nil {
+		planned := s.Next.StartTime
+		if a := s.NextAttempt; a != nil {
+			planned = a.StartTime
+		}
+		fmt.Printf("Next:    %s, planned %s\n", slotName(s.Next, s.NextAttempt), clock(planned))
+	}
+	fmt.Println(describeDelay(s.Delay))
+
+	if !s.ProjectedEnd.IsZero() {
+		fmt.Printf("Projected end of day: %s (planned %s, %s)\n", clock(s.ProjectedEnd), clock(s.PlannedEnd), describeDelay(s.ProjectedEnd.Sub(s.PlannedEnd)))
+	}
+
+	if len(s.AtRisk) > 0 {
+		fmt.Println("\nRounds at risk of overrunning:")
+		for _, r := range s.AtRisk {
+			fmt.Printf("  %s: planned end %s, projected %s (+%s)\n", r.Round.EventName, clock(r.Round.EndTime), clock(r.ProjectedEnd), formatDuration(r.Overrun()))
+		}
+	}
+
+	fmt.Printf("\n%-44s %-13s %-13s %s\n", "Group", "Planned", "Actual", "Start")
+	for _, t := range s.Groups {
+		actual := ""
+		switch {
+		case !t.ActualStart.IsZero():
+			actual = clock(t.ActualStart) + "-"
+			if !t.ActualEnd.IsZero() {
+				actual += clock(t.ActualEnd)
+			}
+		case !t.ProjectedStart.IsZero():
+			actual = "~" + clock(t.ProjectedStart) + "-" + clock(t.ProjectedEnd)
+		}
+		fmt.Printf("%-44s %-13s %-13s %s\n", slotName(t.Group, t.Attempt), timeRange(t.PlannedStart, t.PlannedEnd), actual, describeDelay(t.StartDelay()))
+	}
+}
+
+// slotName names the group, and the attempt when scrambled per attempt
+func slotName(g *models.Group, a *models.Attempt) string {
+	if a == nil {
+		return g.EventName
+	}
+	return fmt.Sprintf("%s, Attempt %d", g.EventName, a.Number)
+}
+
+// printReadiness prints the checks and reports whether they all passed
+func printReadiness(results []models.CheckResult) bool {
+	failed := 0
+	for _, r := range results {
+		if r.Err != nil {
+			failed++
+			fmt.Printf("FAIL  %s: %v\n", r.Name, r.Err)
+		} else {
+			fmt.Printf("PASS  %s\n", r.Name)
+		}
+	}
+
+	if failed > 0 {
+		fmt.Printf("\n%d of %d checks failed\n", failed, len(results))
+		return false
+	}
+	fmt.Printf("\nAll %d checks passed\n", len(results))
+	return true
+}
+
+// describeDelay says how far behind, or ahead when negative, the schedule is
+func describeDelay(d time.Duration) string {
+	switch {
+	case d >= time.Minute:
+		return formatDuration(d) + " behind schedule"
+	case d <= -time.Minute:
+		return formatDuration(-d) + " ahead of schedule"
+	default:
+		return "on schedule"
+	}
+}
+
+func formatDuration(d time.Duration) string {
+	hours := int(d.Hours())
+	minutes := int(d.Minutes()) % 60
+	if hours == 0 {
+		return fmt.Sprintf("%dm", minutes)
+	}
+	return fmt.Sprintf("%dh %dm", hours, minutes)
+}
@@ -0,0 +1,54 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/larsjohanfolde/scrambleman/scrambledesk/internal/models"
+	"golang.org/x/term"
+)
+
+// readPassphrase reads the passphrase sealing the scramble passwords from the
+// environment, or asks for it without echoing it. A new passphrase is asked for twice.
+func readPassphrase(new bool) (string, error) {
+	if passphrase := os.Getenv(models.PassphraseEnv); passphrase != "" {
+		return passphrase, nil
+	}
+
+	prompt := "Passphrase for the scramble passwords: "
+	if new {
+		prompt = "Choose a passphrase for the scramble passwords: "
+	}
+	passphrase, err := readHidden(prompt)
+	if err != nil || !new {
+		return passphrase, err
+	}
+
+	again, err := readHidden("Repeat the passphrase: ")
+	if err != nil {
+		return "", err
+	}
+	if again != passphrase {
+		return "", errors.New("the passphrases do not match")
+	}
+	return passphrase, nil
+}
+
+// readHidden reads a line from the terminal without echoing it. The passphrase
+// is never read from a pipe, where it could not be hidden.
+func readHidden(prompt string) (string, error) {
+	fd := int(os.Stdin.Fd())
+	if !term.IsTerminal(fd) {
+		return "", fmt.Errorf("stdin is not a terminal, give the passphrase in %s", models.PassphraseEnv)
+	}
+
+	fmt.Print(prompt)
+	line, err := term.ReadPassword(fd)
+	fmt.Println()
+	if err != nil {
+		return "", fmt.Errorf("could not read passphrase: %w", err)
+	}
+	return strings.TrimSpace(string(line)), nil
+}
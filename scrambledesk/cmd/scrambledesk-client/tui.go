@@ -0,0 +1,281 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"runtime"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/larsjohanfolde/scrambleman/scrambledesk/config"
+	"github.com/larsjohanfolde/scrambleman/scrambledesk/internal/models"
+)
+
+const (
+	ansiClear  = "\x1b[H\x1b[2J"
+	ansiReset  = "\x1b[0m"
+	ansiBold   = "\x1b[1m"
+	ansiDim    = "\x1b[2m"
+	ansiRed    = "\x1b[31m"
+	ansiGreen  = "\x1b[32m"
+	ansiYellow = "\x1b[33m"
+	ansiCyan   = "\x1b[36m"
+	hideCursor = "\x1b[?25l"
+	showCursor = "\x1b[?25h"
+)
+
+func runTUI(args []string) error {
+	flags := flag.NewFlagSet("tui", flag.ExitOnError)
+	maxEarly := flags.Duration("max-early", models.DefaultMaxEarly, "How long before its planned start a group can be opened without an extra confirmation")
+	flags.Parse(args)
+
+	if runtime.GOOS == "windows" {
+		return fmt.Errorf("the terminal console is not supported on Windows")
+	}
+
+	d, err := newDesk(*maxEarly)
+	if err != nil {
+		return err
+	}
+
+	restore, err := rawTerminal()
+	if err != nil {
+		return fmt.Errorf("could not set up terminal: %w", err)
+	}
+
+	// Messages of the models package would tear up the screen, show them in the footer instead
+	out := os.Stdout
+	models.Log = d
+	defer func() {
+		models.Log = out
+		fmt.Fprint(out, showCursor, ansiClear)
+		restore()
+	}()
+	fmt.Fprint(out, hideCursor)
+
+	stop := make(chan struct{})
+	defer close(stop)
+	go d.watchDisplay(10*time.Second, stop)
+
+	keys := make(chan byte)
+	go readKeys(keys)
+
+	ticker := time.NewTicker(time.Second)
+	defer ticker.Stop()
+
+	for {
+		renderTUI(out, d)
+
+		// Stdin is gone, quit once the running action is done
+		if busy, _, _ := d.status(); keys == nil && busy == "" {
+			return nil
+		}
+
+		select {
+		case key, ok := <-keys:
+			if !ok {
+				keys = nil
+				continue
+			}
+			if prompt, ok := d.confirm.Pending(); ok {
+				switch key {
+				case 'y', 'Y':
+					d.confirm.Answer(prompt.ID, true)
+				case 'n', 'N', 27:
+					d.confirm.Answer(prompt.ID, false)
+				}
+				continue
+			}
+
+			switch key {
+			case 'q', 3:
+				if busy, _, _ := d.status(); busy == "" {
+					return nil
+				}
+			case 'n':
+				d.next()
+			case 'h':
+				d.handIn()
+			case 'r':
+				d.reopen()
+			case 'u':
+				d.undo()
+			}
+		case <-d.changed:
+		case <-ticker.C:
+		}
+	}
+}
+
+// rawTerminal switches the terminal to raw mode and returns a function restoring it
+func rawTerminal() (func(), error) {
+	saved, err := stty("-g")
+	if err != nil {
+		return nil, err
+	}
+
+	_, err = stty("raw", "-echo")
+	if err != nil {
+		return nil, err
+	}
+	return func() { stty(strings.TrimSpace(saved)) }, nil
+}
+
+func stty(args ...string) (string, error) {
+	cmd := exec.Command("stty", args...)
+	cmd.Stdin = os.Stdin
+	out, err := cmd.Output()
+	return string(out), err
+}
+
+func terminalSize() (rows, cols int) {
+	out, err := stty("size")
+	if err != nil {
+		return 24, 80
+	}
+	fields := strings.Fields(out)
+	if len(fields) != 2 {
+		return 24, 80
+	}
+	rows, _ = strconv.Atoi(fields[0])
+	cols, _ = strconv.Atoi(fields[1])
+	if rows <= 0 || cols <= 0 {
+		return 24, 80
+	}
+	return rows, cols
+}
+
+func readKeys(keys chan<- byte) {
+	buf := make([]byte, 1)
+	for {
+		_, err := os.Stdin.Read(buf)
+		if err != nil {
+			close(keys)
+			return
+		}
+		keys <- buf[0]
+	}
+}
+
+var stateColors = map[string]string{
+	string(models.StateScramblesOpen): ansiGreen + ansiBold,
+	string(models.StateHandIn):        ansiYellow,
+	string(models.StateClosed):        ansiDim,
+	string(models.StateSkipped):       ansiDim,
+	"next":                            ansiCyan,
+	"done":                            ansiDim,
+}
+
+func renderTUI(out io.Writer, d *desk) {
+	rows, cols := terminalSize()
+	o := d.overview()
+
+	checked, connErr := d.displayStatus()
+	display := ansiDim + "Display: checking..." + ansiReset
+	if !checked.IsZero() && connErr == nil {
+		display = fmt.Sprintf("%sDisplay: connected (%s)%s", ansiGreen, config.IP, ansiReset)
+	} else if !checked.IsZero() {
+		display = fmt.Sprintf("%sDisplay: unreachable (%v)%s", ansiRed, connErr, ansiReset)
+	}
+
+	header := []string{
+		display,
+		fmt.Sprintf("%s%s%s  %s  %s", ansiBold, o.Name, ansiReset, strings.Join(o.Rooms, ", "), time.Now().Format("15:04:05")),
+		"",
+		"Current: -",
+		"Next:    -",
+		"",
+		fmt.Sprintf("%s%-44s %-13s %-13s %s%s", ansiBold, "Round / group", "Planned", "Actual", "State", ansiReset),
+	}
+	if o.Current != "" {
+		header[3] = fmt.Sprintf("Current: %s%s%s  opened %s", ansiGreen, o.Current, ansiReset, o.CurrentOpened)
+	}
+	if o.Next != "" {
+		header[4] = fmt.Sprintf("Next:    %s%s%s  planned %s", ansiCyan, o.Next, ansiReset, o.NextPlanned)
+	}
+
+	footer := []string{""}
+	for _, note := range d.recentNotes() {
+		footer = append(footer, ansiYellow+note+ansiReset)
+	}
+	footer = append(footer, ansiDim+"[n] next  [h] hand-in  [r] reopen  [u] undo  [q] quit"+ansiReset)
+	busy, message, failed := d.status()
+	if prompt, ok := d.confirm.Pending(); ok {
+		footer = append(footer, ansiYellow+ansiBold+strings.ReplaceAll(prompt.Message, "\n", " ")+" [y/N]"+ansiReset)
+	} else if busy != "" {
+		footer = append(footer, ansiCyan+busy+"..."+ansiReset)
+	} else if failed {
+		footer = append(footer, ansiRed+message+ansiReset)
+	} else {
+		footer = append(footer, message)
+	}
+
+	body := make([]string, len(o.Rows))
+	current := 0
+	for i, r := range o.Rows {
+		if r.State == string(models.StateScramblesOpen) || r.State == string(models.StateHandIn) {
+			current = i
+		}
+		if !r.Group {
+			body[i] = fmt.Sprintf("%s%-44s %-13s%s", stateColors[r.State], r.Name, r.Planned, ansiReset)
+			continue
+		}
+		name := "  " + r.Name
+		if r.Attempt {
+			name = "    " + r.Name
+		}
+		body[i] = fmt.Sprintf("%s%-44s %-13s %-13s %s%s", stateColors[r.State], name, r.Planned, r.Actual, r.State, ansiReset)
+	}
+
+	// Keep the current group in view when the schedule is taller than the terminal
+	space := max(rows-len(header)-len(footer), 1)
+	start := max(current-space/3, 0)
+	end := min(start+space, len(body))
+	start = max(end-space, 0)
+
+	var b strings.Builder
+	b.WriteString(ansiClear)
+	for _, l := range header {
+		b.WriteString(truncate(l, cols) + "\r\n")
+	}
+	for _, l := range body[start:end] {
+		b.WriteString(truncate(l, cols) + "\r\n")
+	}
+	for i := end - start; i < space; i++ {
+		b.WriteString("\r\n")
+	}
+	for i, l := range footer {
+		b.WriteString(truncate(l, cols))
+		if i < len(footer)-1 {
+			b.WriteString("\r\n")
+		}
+	}
+	fmt.Fprint(out, b.String())
+}
+
+// truncate cuts the line to the terminal width, ignoring escape codes when counting
+func truncate(s string, width int) string {
+	var b strings.Builder
+	visible := 0
+	escape := false
+	for _, r := range s {
+		switch {
+		case r == '\x1b':
+			escape = true
+		case escape:
+			if r == 'm' {
+				escape = false
+			}
+		case visible >= width:
+			continue
+		default:
+			visible++
+		}
+		b.WriteRune(r)
+	}
+	return b.String() + ansiReset
+}
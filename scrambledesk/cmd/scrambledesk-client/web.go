@@ -0,0 +1,359 @@
+package main
+
+import (
+	"crypto/rand"
+	"crypto/subtle"
+	"embed"
+	"encoding/hex"
+	"errors"
+	"flag"
+	"fmt"
+	"html/template"
+	"net"
+	"net/http"
+	"net/url"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/larsjohanfolde/scrambleman/scrambledesk/config"
+	"github.com/larsjohanfolde/scrambleman/scrambledesk/internal/models"
+)
+
+const sessionCookie = "scrambledesk_session"
+
+const (
+	// maxLoginFailures wrong PINs in a row lock the login for loginLockout
+	maxLoginFailures = 5
+	loginLockout     = 5 * time.Minute
+)
+
+//go:embed templates/dashboard.html
+var templateFS embed.FS
+
+var templates = template.Must(template.New("").Funcs(template.FuncMap{
+	"join": strings.Join,
+}).ParseFS(templateFS, "templates/dashboard.html"))
+
+type dashboard struct {
+	desk *desk
+	pin  string
+	// host is the host of the address the dashboard is served on, lan allows any IP address
+	host string
+	lan  bool
+
+	mu          sync.Mutex
+	sessions    map[string]bool
+	failures    int
+	lockedUntil time.Time
+}
+
+type dashboardPage struct {
+	Refresh    int
+	Overview   overview
+	IP         string
+	Checked    bool
+	Connection error
+	Prompt     *models.Prompt
+	Busy       string
+	Message    string
+	Failed     bool
+	Profiles   int64
+}
+
+func runWeb(args []string) error {
+	flags := flag.NewFlagSet("web", flag.ExitOnError)
+	addr := flags.String("addr", "127.0.0.1:8080", "Address to serve the dashboard on")
+	lan := flags.Bool("lan", false, "Serve the dashboard on every network interface, requires -pin")
+	pin := flags.String("pin", "", "PIN needed to use the dashboard")
+	maxEarly := flags.Duration("max-early", models.DefaultMaxEarly, "How long before its planned start a group can be opened without an extra confirmation")
+	flags.Parse(args)
+
+	if *lan {
+		_, port, err := net.SplitHostPort(*addr)
+		if err != nil {
+			return fmt.Errorf("invalid address %q: %w", *addr, err)
+		}
+		*addr = net.JoinHostPort("", port)
+		if *pin == "" {
+			return errors.New("a PIN is required when serving the dashboard on the network")
+		}
+	}
+
+	d, err := newDesk(*maxEarly)
+	if err != nil {
+		return err
+	}
+
+	stop := make(chan struct{})
+	defer close(stop)
+	go d.watchDisplay(10*time.Second, stop)
+
+	host, _, err := net.SplitHostPort(*addr)
+	if err != nil {
+		return fmt.Errorf("invalid address %q: %w", *addr, err)
+	}
+	s := &dashboard{desk: d, pin: *pin, host: host, lan: *lan, sessions: map[string]bool{}}
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("GET /{$}", s.auth(s.handleDashboard))
+	mux.HandleFunc("GET /login", s.handleLoginPage)
+	mux.HandleFunc("POST /login", s.handleLogin)
+	mux.HandleFunc("POST /next", s.auth(s.action(d.next)))
+	mux.HandleFunc("POST /hand-in", s.auth(s.action(d.handIn)))
+	mux.HandleFunc("POST /reopen", s.auth(s.action(d.reopen)))
+	mux.HandleFunc("POST /undo", s.auth(s.action(d.undo)))
+	mux.HandleFunc("POST /confirm", s.auth(s.handleConfirm))
+	mux.HandleFunc("GET /profiles.pdf", s.auth(s.handleProfiles))
+
+	server := &http.Server{
+		Addr:              *addr,
+		Handler:           mux,
+		ReadHeaderTimeout: 10 * time.Second,
+	}
+
+	fmt.Printf("Dashboard running on http://%s\n", displayAddr(*addr))
+	return server.ListenAndServe()
+}
+
+// displayAddr replaces an empty host with the LAN address of this machine
+func displayAddr(addr string) string {
+	host, port, err := net.SplitHostPort(addr)
+	if err != nil || host != "" {
+		return addr
+	}
+
+	conn, err := net.Dial("udp", "8.8.8.8:80")
+	if err != nil {
+		return net.JoinHostPort("localhost", port)
+	}
+	defer conn.Close()
+	return net.JoinHostPort(conn.LocalAddr().(*net.UDPAddr).IP.String(), port)
+}
+
+// auth sends the visitor to the login page unless no PIN is set or they are logged in.
+// Requests for other host names and state changing requests from other sites are rejected.
+func (s *dashboard) auth(next http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if !s.validRequest(w, r) {
+			return
+		}
+		if s.pin != "" && !s.loggedIn(r) {
+			if r.Method == http.MethodGet {
+				http.Redirect(w, r, "/login", http.StatusSeeOther)
+				return
+			}
+			http.Error(w, "not logged in", http.StatusUnauthorized)
+			return
+		}
+		next(w, r)
+	}
+}
+
+// validRequest rejects requests for a host name the dashboard is not served
+// on, which is how a DNS rebinding page would reach it, and POST requests
+// without an Origin of the dashboard itself
+func (s *dashboard) validRequest(w http.ResponseWriter, r *http.Request) bool {
+	if !s.validHost(r.Host) {
+		http.Error(w, "unknown host", http.StatusForbidden)
+		return false
+	}
+	if r.Method == http.MethodPost && !sameOrigin(r) {
+		http.Error(w, "cross-origin request rejected", http.StatusForbidden)
+		return false
+	}
+	return true
+}
+
+// validHost accepts localhost, loopback addresses and the host of -addr.
+// With -lan the dashboard is reached by IP address, so any IP address is accepted.
+func (s *dashboard) validHost(hostport string) bool {
+	host, _, err := net.SplitHostPort(hostport)
+	if err != nil {
+		host = hostport
+	}
+	host = strings.Trim(host, "[]")
+
+	ip := net.ParseIP(host)
+	switch {
+	case strings.EqualFold(host, "localhost"):
+		return true
+	case ip != nil && (ip.IsLoopback() || s.lan):
+		return true
+	default:
+		return s.host != "" && strings.EqualFold(host, s.host)
+	}
+}
+
+func sameOrigin(r *http.Request) bool {
+	origin := r.Header.Get("Origin")
+	if origin == "" {
+		return false
+	}
+	u, err := url.Parse(origin)
+	return err == nil && u.Host == r.Host
+}
+
+func (s *dashboard) loggedIn(r *http.Request) bool {
+	cookie, err := r.Cookie(sessionCookie)
+	if err != nil {
+		return false
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.sessions[cookie.Value]
+}
+
+func (s *dashboard) handleLoginPage(w http.ResponseWriter, r *http.Request) {
+	if !s.validRequest(w, r) {
+		return
+	}
+	if s.pin == "" || s.loggedIn(r) {
+		http.Redirect(w, r, "/", http.StatusSeeOther)
+		return
+	}
+	s.render(w, "login", struct {
+		Refresh int
+		Failed  bool
+		Locked  bool
+	}{Failed: r.URL.Query().Has("failed"), Locked: r.URL.Query().Has("locked")})
+}
+
+func (s *dashboard) handleLogin(w http.ResponseWriter, r *http.Request) {
+	if !s.validRequest(w, r) {
+		return
+	}
+	switch s.checkPIN(r.FormValue("pin")) {
+	case pinLocked:
+		http.Redirect(w, r, "/login?locked", http.StatusSeeOther)
+		return
+	case pinWrong:
+		http.Redirect(w, r, "/login?failed", http.StatusSeeOther)
+		return
+	}
+
+	token := make([]byte, 32)
+	_, err := rand.Read(token)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	session := hex.EncodeToString(token)
+
+	s.mu.Lock()
+	s.sessions[session] = true
+	s.mu.Unlock()
+
+	http.SetCookie(w, &http.Cookie{
+		Name:     sessionCookie,
+		Value:    session,
+		Path:     "/",
+		HttpOnly: true,
+		SameSite: http.SameSiteStrictMode,
+	})
+	http.Redirect(w, r, "/", http.StatusSeeOther)
+}
+
+type pinResult int
+
+const (
+	pinCorrect pinResult = iota
+	pinWrong
+	pinLocked
+)
+
+// checkPIN compares the PIN, counting wrong PINs across every client so
+// guessing in parallel runs into the lockout as quickly as guessing in turn
+func (s *dashboard) checkPIN(pin string) pinResult {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if time.Now().Before(s.lockedUntil) {
+		return pinLocked
+	}
+	if subtle.ConstantTimeCompare([]byte(pin), []byte(s.pin)) == 1 {
+		s.failures = 0
+		return pinCorrect
+	}
+
+	s.failures++
+	if s.failures >= maxLoginFailures {
+		s.failures = 0
+		s.lockedUntil = time.Now().Add(loginLockout)
+		fmt.Printf("%d wrong PINs, login locked until %s\n", maxLoginFailures, s.lockedUntil.Format("15:04:05"))
+		return pinLocked
+	}
+	return pinWrong
+}
+
+func (s *dashboard) handleDashboard(w http.ResponseWriter, r *http.Request) {
+	d := s.desk
+	page := dashboardPage{
+		Overview: d.overview(),
+		IP:       config.IP,
+	}
+
+	checked, connErr := d.displayStatus()
+	page.Checked = !checked.IsZero()
+	page.Connection = connErr
+	page.Busy, page.Message, page.Failed = d.status()
+	if prompt, ok := d.confirm.Pending(); ok {
+		page.Prompt = &prompt
+	}
+
+	// Refresh quickly while an action runs so its prompt shows up
+	page.Refresh = 30
+	if page.Busy != "" {
+		page.Refresh = 2
+	}
+	if page.Prompt != nil {
+		page.Refresh = 0
+	}
+
+	info, err := os.Stat("profiles.pdf")
+	if err == nil {
+		page.Profiles = info.ModTime().Unix()
+	}
+
+	s.render(w, "dashboard", page)
+}
+
+func (s *dashboard) action(start func() bool) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if !start() {
+			http.Error(w, "another action is still running", http.StatusConflict)
+			return
+		}
+		http.Redirect(w, r, "/", http.StatusSeeOther)
+	}
+}
+
+func (s *dashboard) handleConfirm(w http.ResponseWriter, r *http.Request) {
+	id, err := strconv.Atoi(r.FormValue("id"))
+	if err != nil {
+		http.Error(w, "invalid prompt id", http.StatusBadRequest)
+		return
+	}
+
+	err = s.desk.confirm.Answer(id, r.FormValue("answer") == "yes")
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusConflict)
+		return
+	}
+	http.Redirect(w, r, "/", http.StatusSeeOther)
+}
+
+func (s *dashboard) handleProfiles(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Cache-Control", "no-store")
+	http.ServeFile(w, r, "profiles.pdf")
+}
+
+func (s *dashboard) render(w http.ResponseWriter, name string, data any) {
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	err := templates.ExecuteTemplate(w, name, data)
+	if err != nil {
+		fmt.Println("Could not render dashboard:", err)
+	}
+}
@@ -0,0 +1,157 @@
+package main
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"log"
+	"net/http"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/larsjohanfolde/scrambleman/scrambledesk/internal/protocol"
+)
+
+const (
+	scrambleFile = "scrambles.pdf"
+	groupFile    = "group.pdf"
+)
+
+func main() {
+	addr := flag.String("addr", fmt.Sprintf(":%d", protocol.Port), "Address to receive uploads on")
+	viewerAddr := flag.String("viewer", "127.0.0.1:2014", "Address the local viewer fetches the PDFs from, empty to disable")
+	dir := flag.String("dir", defaultDir(), "Directory to store the received PDFs in")
+	cert := flag.String("cert", "server.crt", "Server certificate")
+	key := flag.String("key", "server.key", "Server key")
+	ca := flag.String("ca", "ca.crt", "CA used to verify client certificates")
+	flag.Parse()
+
+	store, err := NewStore(*dir)
+	if err != nil {
+		log.Fatalf("Could not create store: %v", err)
+	}
+
+	tlsConfig, err := serverTLSConfig(*cert, *key, *ca)
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	uploads := http.NewServeMux()
+	uploads.Handle(protocol.ScramblePath, uploadHandler(store, scrambleFile))
+	uploads.Handle(protocol.GroupPath, uploadHandler(store, groupFile))
+
+	server := &http.Server{
+		Addr:              *addr,
+		Handler:           uploads,
+		TLSConfig:         tlsConfig,
+		ReadHeaderTimeout: 10 * time.Second,
+		ReadTimeout:       time.Minute,
+		WriteTimeout:      time.Minute,
+	}
+
+	if *viewerAddr != "" {
+		go func() {
+			log.Printf("Serving viewer on http://%s", *viewerAddr)
+			err := http.ListenAndServe(*viewerAddr, viewerHandler(store))
+			if err != nil {
+				log.Fatalf("Viewer stopped: %v", err)
+			}
+		}()
+	}
+
+	log.Printf("Receiving uploads on %s, storing them in %s", *addr, *dir)
+	err = server.ListenAndServeTLS("", "")
+	if err != nil {
+		log.Fatal(err)
+	}
+}
+
+func defaultDir() string {
+	dir, err := os.UserCacheDir()
+	if err != nil {
+		return "scrambledesk-display"
+	}
+	return filepath.Join(dir, "ScrambleDesk", "display")
+}
+
+func serverTLSConfig(certFile, keyFile, caFile string) (*tls.Config, error) {
+	cert, err := tls.LoadX509KeyPair(certFile, keyFile)
+	if err != nil {
+		return nil, fmt.Errorf("could not load server certificate: %w", err)
+	}
+
+	caPEM, err := os.ReadFile(caFile)
+	if err != nil {
+		return nil, fmt.Errorf("could not read CA: %w", err)
+	}
+
+	pool := x509.NewCertPool()
+	if !pool.AppendCertsFromPEM(caPEM) {
+		return nil, fmt.Errorf("no certificates found in %s", caFile)
+	}
+
+	return &tls.Config{
+		Certificates: []tls.Certificate{cert},
+		ClientCAs:    pool,
+		ClientAuth:   tls.RequireAndVerifyClientCert,
+		MinVersion:   tls.VersionTLS12,
+	}, nil
+}
+
+func uploadHandler(store *Store, name string) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodPost {
+			w.Header().Set("Allow", http.MethodPost)
+			http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+			return
+		}
+
+		r.Body = http.MaxBytesReader(w, r.Body, protocol.MaxUploadSize)
+		file, _, err := r.FormFile(protocol.FormField)
+		if err != nil {
+			http.Error(w, fmt.Sprintf("missing %q form field: %v", protocol.FormField, err), http.StatusBadRequest)
+			return
+		}
+		defer file.Close()
+
+		err = store.Put(name, file)
+		if errors.Is(err, ErrNotPDF) {
+			http.Error(w, err.Error(), http.StatusUnsupportedMediaType)
+			return
+		}
+		if err != nil {
+			log.Printf("Could not store %s: %v", name, err)
+			http.Error(w, "could not store file", http.StatusInternalServerError)
+			return
+		}
+
+		log.Printf("Received %s from %s", name, clientName(r))
+		fmt.Fprintln(w, "ok")
+	})
+}
+
+func viewerHandler(store *Store) http.Handler {
+	mux := http.NewServeMux()
+	for _, name := range []string{scrambleFile, groupFile} {
+		mux.HandleFunc("/"+name, func(w http.ResponseWriter, r *http.Request) {
+			w.Header().Set("Cache-Control", "no-store")
+			http.ServeFile(w, r, store.Path(name))
+		})
+	}
+	mux.HandleFunc("/status", func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(store.Entries())
+	})
+	return mux
+}
+
+func clientName(r *http.Request) string {
+	if r.TLS != nil && len(r.TLS.PeerCertificates) > 0 {
+		return r.TLS.PeerCertificates[0].Subject.CommonName
+	}
+	return r.RemoteAddr
+}
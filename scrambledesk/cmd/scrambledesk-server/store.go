@@ -0,0 +1,109 @@
+package main
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+
+	"github.com/larsjohanfolde/scrambleman/scrambledesk/internal/fsutil"
+)
+
+var ErrNotPDF = errors.New("file is not a PDF")
+
+// Store keeps the latest upload of each kind on disk. Files are replaced
+// atomically so a viewer never reads a half-written PDF.
+type Store struct {
+	dir string
+
+	mu      sync.RWMutex
+	updated map[string]time.Time
+}
+
+type Entry struct {
+	Name    string    `json:"name"`
+	Updated time.Time `json:"updated"`
+}
+
+func NewStore(dir string) (*Store, error) {
+	err := os.MkdirAll(dir, 0755)
+	if err != nil {
+		return nil, err
+	}
+
+	s := &Store{dir: dir, updated: make(map[string]time.Time)}
+	for _, name := range []string{scrambleFile, groupFile} {
+		info, err := os.Stat(s.Path(name))
+		if err == nil {
+			s.updated[name] = info.ModTime()
+		}
+	}
+	return s, nil
+}
+
+func (s *Store) Path(name string) string {
+	return filepath.Join(s.dir, name)
+}
+
+// Put writes r to name, replacing the previous file once the new one is
+// completely on disk
+func (s *Store) Put(name string, r io.Reader) error {
+	header := make([]byte, 5)
+	_, err := io.ReadFull(r, header)
+	if err != nil || !bytes.Equal(header, []byte("%PDF-")) {
+		return ErrNotPDF
+	}
+
+	tmp, err := os.CreateTemp(s.dir, name+".*.tmp")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+
+	_, err = io.Copy(tmp, io.MultiReader(bytes.NewReader(header), r))
+	if err != nil {
+		tmp.Close()
+		return err
+	}
+
+	err = tmp.Sync()
+	if err != nil {
+		tmp.Close()
+		return err
+	}
+
+	err = tmp.Close()
+	if err != nil {
+		return err
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	err = os.Rename(tmp.Name(), s.Path(name))
+	if err != nil {
+		return fmt.Errorf("could not replace %s: %w", name, err)
+	}
+	fsutil.SyncDir(s.dir)
+
+	s.updated[name] = time.Now()
+	return nil
+}
+
+func (s *Store) Entries() []Entry {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	var entries []Entry
+	for _, name := range []string{scrambleFile, groupFile} {
+		updated, ok := s.updated[name]
+		if ok {
+			entries = append(entries, Entry{Name: name, Updated: updated})
+		}
+	}
+	return entries
+}
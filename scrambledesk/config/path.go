@@ -6,12 +6,23 @@ import (
 	"os"
 	"path/filepath"
 	"runtime"
+	"strings"
+
+	"github.com/larsjohanfolde/scrambleman/scrambledesk/internal/protocol"
 )
 
+// DefaultAPIBaseURL is used unless another WCA API base URL has been stored
+const DefaultAPIBaseURL = "https://www.worldcubeassociation.org"
+
 var AppDataDir string
 var FontDir string
 var IpFile string
 var IP string
+var APIURLFile string
+var APIBaseURL string
+var BackupDir string
+var ScramblesDir string
+var AuditFile string
 var ScrambleURL string
 var CompetitorListURL string
 var ClientCrt string
@@ -46,9 +57,9 @@ func init() {
 		log.Fatal(err)
 	}
 
-	directories := []string{"archive", "avatars", "fonts", "certificates"}
+	directories := []string{"archive", "avatars", "fonts", "certificates", "backups", "scrambles"}
 	for _, d := range directories {
-        dir := filepath.Join(AppDataDir, d)
+		dir := filepath.Join(AppDataDir, d)
 		err = os.MkdirAll(dir, 0755)
 		if err != nil {
 			log.Fatal(err)
@@ -61,9 +72,20 @@ func init() {
 		log.Fatalf("Could not read IP from file: %v", err)
 	}
 	IP = string(ipBytes)
-	CompetitorListURL = fmt.Sprintf("https://%s:2013/group", IP)
-	ScrambleURL = fmt.Sprintf("https://%s:2013/upload", IP)
+	CompetitorListURL = fmt.Sprintf("https://%s:%d%s", IP, protocol.Port, protocol.GroupPath)
+	ScrambleURL = fmt.Sprintf("https://%s:%d%s", IP, protocol.Port, protocol.ScramblePath)
 	FontDir = filepath.Join(AppDataDir, "fonts")
+	BackupDir = filepath.Join(AppDataDir, "backups")
+	ScramblesDir = filepath.Join(AppDataDir, "scrambles")
+	AuditFile = filepath.Join(AppDataDir, "audit.jsonl")
+
+	// Allows pointing the desk at a local stand-in for the WCA API
+	APIURLFile = filepath.Join(AppDataDir, "api-url.txt")
+	APIBaseURL = DefaultAPIBaseURL
+	apiURLBytes, err := os.ReadFile(APIURLFile)
+	if err == nil && strings.TrimSpace(string(apiURLBytes)) != "" {
+		APIBaseURL = strings.TrimRight(strings.TrimSpace(string(apiURLBytes)), "/")
+	}
 
 	// Certificates
 	ClientCrt = filepath.Join(AppDataDir, "certificates", "client.crt")
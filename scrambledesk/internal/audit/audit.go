@@ -0,0 +1,252 @@
+// Package audit keeps an append-only log of who accessed which scramble set.
+// Every entry holds the hash of the entry before it, so editing or removing an
+// entry breaks the chain. The last entry a competition knows of is kept in the
+// competition as an Anchor, so removing the last entries or rewriting the
+// whole log is noticed too.
+package audit
+
+import (
+	"bufio"
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"os/user"
+	"sync"
+	"time"
+)
+
+const (
+	ActionSend      = "send"
+	ActionOpen      = "open"
+	ActionHandIn    = "hand-in"
+	ActionClose     = "close"
+	ActionStartFrom = "start-from"
+	ActionUndo      = "undo"
+	ActionRestore   = "restore"
+	ActionCheck     = "check"
+)
+
+var (
+	ErrBrokenChain = errors.New("audit log chain broken")
+	ErrLocked      = errors.New("audit log locked by another process")
+)
+
+const (
+	// lockTimeout is how long Record waits for another process to finish its entry
+	lockTimeout = 5 * time.Second
+	// staleLock is the age after which a lock is taken to be left by a crashed process
+	staleLock = 30 * time.Second
+)
+
+// Entry is one line of the log
+type Entry struct {
+	Seq         int
+	Time        time.Time
+	User        string
+	Host        string
+	Action      string
+	Competition string
+	Activity    string `json:",omitempty"`
+	Detail      string `json:",omitempty"`
+	// Prev is the hash of the entry before, empty for the first entry
+	Prev string
+	Hash string
+}
+
+// Anchor is an entry of the log kept outside it, which the log must still hold
+type Anchor struct {
+	Seq  int
+	Hash string
+}
+
+// mu serialises appends from the same process
+var mu sync.Mutex
+
+// Record appends an entry to the log at path. The command line and a desk
+// may record at the same time, so the log is locked from reading the last
+// entry until the new one is appended. Returns the new entry as an anchor.
+func Record(path, action, competition, activity, detail string) (Anchor, error) {
+	mu.Lock()
+	defer mu.Unlock()
+
+	unlock, err := lock(path)
+	if err != nil {
+		return Anchor{}, err
+	}
+	defer unlock()
+
+	entries, err := Read(path)
+	if err != nil && !errors.Is(err, os.ErrNotExist) {
+		return Anchor{}, err
+	}
+
+	entry := Entry{
+		Seq:         1,
+		Time:        time.Now().UTC(),
+		User:        currentUser(),
+		Host:        hostname(),
+		Action:      action,
+		Competition: competition,
+		Activity:    activity,
+		Detail:      detail,
+	}
+	if len(entries) > 0 {
+		last := entries[len(entries)-1]
+		entry.Seq = last.Seq + 1
+		entry.Prev = last.Hash
+	}
+	entry.Hash, err = entry.hash()
+	if err != nil {
+		return Anchor{}, err
+	}
+
+	line, err := json.Marshal(entry)
+	if err != nil {
+		return Anchor{}, err
+	}
+
+	file, err := os.OpenFile(path, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0600)
+	if err != nil {
+		return Anchor{}, err
+	}
+	_, err = file.Write(append(line, '\n'))
+	if err != nil {
+		file.Close()
+		return Anchor{}, err
+	}
+	err = file.Sync()
+	if err != nil {
+		file.Close()
+		return Anchor{}, err
+	}
+	err = file.Close()
+	if err != nil {
+		return Anchor{}, err
+	}
+	return Anchor{Seq: entry.Seq, Hash: entry.Hash}, nil
+}
+
+// lock creates a lock file next to the log, which works the same on every
+// platform. A lock left by a crashed process is taken over once it is stale.
+func lock(path string) (func(), error) {
+	lockPath := path + ".lock"
+	deadline := time.Now().Add(lockTimeout)
+	for {
+		file, err := os.OpenFile(lockPath, os.O_CREATE|os.O_EXCL|os.O_WRONLY, 0600)
+		if err == nil {
+			file.Close()
+			return func() { os.Remove(lockPath) }, nil
+		}
+		if !errors.Is(err, os.ErrExist) {
+			return nil, err
+		}
+
+		info, err := os.Stat(lockPath)
+		if err == nil && time.Since(info.ModTime()) > staleLock {
+			os.Remove(lockPath)
+			continue
+		}
+		if time.Now().After(deadline) {
+			return nil, ErrLocked
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+}
+
+// Read returns the entries of the log, without checking the chain
+func Read(path string) ([]Entry, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	var entries []Entry
+	scanner := bufio.NewScanner(bytes.NewReader(data))
+	scanner.Buffer(make([]byte, 64*1024), 1024*1024)
+	line := 0
+	for scanner.Scan() {
+		line++
+		if len(bytes.TrimSpace(scanner.Bytes())) == 0 {
+			continue
+		}
+		var e Entry
+		err = json.Unmarshal(scanner.Bytes(), &e)
+		if err != nil {
+			return nil, fmt.Errorf("%w: line %d is not an entry: %v", ErrBrokenChain, line, err)
+		}
+		entries = append(entries, e)
+	}
+	return entries, scanner.Err()
+}
+
+// Verify checks that no entry of the log was changed, removed or inserted, and
+// that the log still holds the anchor unless it is zero. Returns the number of
+// intact entries.
+func Verify(path string, anchor Anchor) (int, error) {
+	entries, err := Read(path)
+	if err != nil {
+		return 0, err
+	}
+
+	prev := ""
+	for i, e := range entries {
+		if e.Seq != i+1 {
+			return i, fmt.Errorf("%w: entry %d has sequence number %d", ErrBrokenChain, i+1, e.Seq)
+		}
+		if e.Prev != prev {
+			return i, fmt.Errorf("%w: entry %d does not follow entry %d", ErrBrokenChain, e.Seq, e.Seq-1)
+		}
+		hash, err := e.hash()
+		if err != nil {
+			return i, err
+		}
+		if hash != e.Hash {
+			return i, fmt.Errorf("%w: entry %d was modified", ErrBrokenChain, e.Seq)
+		}
+		prev = e.Hash
+	}
+
+	if anchor.Seq > len(entries) {
+		return len(entries), fmt.Errorf("%w: entry %d is missing, the log was cut short", ErrBrokenChain, anchor.Seq)
+	}
+	if anchor.Seq > 0 && entries[anchor.Seq-1].Hash != anchor.Hash {
+		return anchor.Seq - 1, fmt.Errorf("%w: entry %d is not the one recorded, the log was rewritten", ErrBrokenChain, anchor.Seq)
+	}
+	return len(entries), nil
+}
+
+// hash is the SHA-256 of the entry without its own hash
+func (e Entry) hash() (string, error) {
+	e.Hash = ""
+	data, err := json.Marshal(e)
+	if err != nil {
+		return "", err
+	}
+	sum := sha256.Sum256(data)
+	return hex.EncodeToString(sum[:]), nil
+}
+
+func currentUser() string {
+	u, err := user.Current()
+	if err == nil && u.Username != "" {
+		return u.Username
+	}
+	for _, env := range []string{"USER", "USERNAME"} {
+		if name := os.Getenv(env); name != "" {
+			return name
+		}
+	}
+	return "unknown"
+}
+
+func hostname() string {
+	host, err := os.Hostname()
+	if err != nil {
+		return "unknown"
+	}
+	return host
+}
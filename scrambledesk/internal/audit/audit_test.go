@@ -0,0 +1,109 @@
+package audit
+
+import (
+	"bytes"
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+// writeLog records n entries and returns the path, the lines of the log and
+// the last entry as an anchor
+func writeLog(t *testing.T, n int) (string, [][]byte, Anchor) {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), "audit.jsonl")
+	var anchor Anchor
+	for i := 0; i < n; i++ {
+		var err error
+		anchor, err = Record(path, ActionOpen, "Test2026", "333-r1-g1", "")
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return path, bytes.SplitAfter(bytes.TrimSuffix(data, []byte("\n")), []byte("\n")), anchor
+}
+
+func TestVerify(t *testing.T) {
+	tests := []struct {
+		name   string
+		change func(lines [][]byte) [][]byte
+		// unanchored verifies without the anchor, as for a log no competition knows of
+		unanchored bool
+		intact     int
+		err        error
+	}{
+		{"intact", func(lines [][]byte) [][]byte { return lines }, false, 4, nil},
+		{"entry modified", func(lines [][]byte) [][]byte {
+			lines[2] = bytes.Replace(lines[2], []byte("333-r1-g1"), []byte("333-r1-g2"), 1)
+			return lines
+		}, false, 2, ErrBrokenChain},
+		{"entry removed", func(lines [][]byte) [][]byte {
+			return append(lines[:1:1], lines[2:]...)
+		}, false, 1, ErrBrokenChain},
+		{"last entry removed", func(lines [][]byte) [][]byte {
+			return lines[:3]
+		}, false, 3, ErrBrokenChain},
+		// Without the anchor removing the last entries leaves a valid chain
+		{"last entry removed unanchored", func(lines [][]byte) [][]byte {
+			return lines[:3]
+		}, true, 3, nil},
+		{"log rewritten", func(lines [][]byte) [][]byte {
+			_, rewritten, _ := writeLog(t, 4)
+			return rewritten
+		}, false, 3, ErrBrokenChain},
+		{"entries reordered", func(lines [][]byte) [][]byte {
+			lines[1], lines[2] = lines[2], lines[1]
+			return lines
+		}, false, 1, ErrBrokenChain},
+		{"entry duplicated", func(lines [][]byte) [][]byte {
+			return append(lines[:2:2], lines[1:]...)
+		}, false, 2, ErrBrokenChain},
+		{"line not an entry", func(lines [][]byte) [][]byte {
+			lines[3] = []byte("not json\n")
+			return lines
+		}, false, 0, ErrBrokenChain},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			path, lines, anchor := writeLog(t, 4)
+			err := os.WriteFile(path, bytes.Join(tt.change(lines), nil), 0600)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if tt.unanchored {
+				anchor = Anchor{}
+			}
+
+			count, err := Verify(path, anchor)
+			if !errors.Is(err, tt.err) {
+				t.Fatalf("Verify() error = %v, want %v", err, tt.err)
+			}
+			if count != tt.intact {
+				t.Errorf("Verify() = %d intact entries, want %d", count, tt.intact)
+			}
+		})
+	}
+}
+
+func TestRecordAfterTamperingKeepsChainBroken(t *testing.T) {
+	path, lines, _ := writeLog(t, 2)
+	lines[0] = bytes.Replace(lines[0], []byte("Test2026"), []byte("Other2026"), 1)
+	err := os.WriteFile(path, bytes.Join(lines, nil), 0600)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	_, err = Record(path, ActionClose, "Test2026", "333-r1-g1", "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, err = Verify(path, Anchor{})
+	if !errors.Is(err, ErrBrokenChain) {
+		t.Fatalf("Verify() error = %v, want %v", err, ErrBrokenChain)
+	}
+}
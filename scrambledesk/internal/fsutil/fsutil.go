@@ -0,0 +1,14 @@
+// Package fsutil holds the file system helpers shared by the desk and the display server
+package fsutil
+
+import "os"
+
+// SyncDir flushes a rename in dir to disk. Not supported on every platform, so errors are ignored
+func SyncDir(dir string) {
+	d, err := os.Open(dir)
+	if err != nil {
+		return
+	}
+	d.Sync()
+	d.Close()
+}
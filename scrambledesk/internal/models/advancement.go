@@ -0,0 +1,81 @@
+package models
+
+import (
+	"math"
+	"sort"
+)
+
+const (
+	AdvancementRanking       = "ranking"
+	AdvancementPercent       = "percent"
+	AdvancementAttemptResult = "attemptResult"
+)
+
+// maxAdvancingShare is the largest share of competitors allowed to proceed to the next round (Regulation 9p1)
+const maxAdvancingShare = 0.75
+
+type AdvancementCondition struct {
+	Type  string `json:"type"`
+	Level int    `json:"level"`
+}
+
+// rankingResult is the result the round is ranked by, the average for average
+// and mean formats and the single otherwise
+func (r *Round) rankingResult(res Result) int {
+	if r.Format == "a" || r.Format == "m" {
+		return res.Average
+	}
+	return res.Best
+}
+
+// AdvancingResults returns the results of the competitors proceeding from this
+// round to the next, ordered by ranking
+func (r *Round) AdvancingResults() []Result {
+	var ranked []Result
+	for _, res := range r.Results {
+		// Competitors without a successful attempt can't advance
+		if res.Ranking > 0 && res.Best > 0 {
+			ranked = append(ranked, res)
+		}
+	}
+	sort.Slice(ranked, func(i, j int) bool {
+		return ranked[i].Ranking < ranked[j].Ranking
+	})
+
+	maxAdvancing := int(math.Floor(float64(len(r.Results)) * maxAdvancingShare))
+	maxRanking := maxAdvancing
+
+	if r.Advancement != nil {
+		switch r.Advancement.Type {
+		case AdvancementRanking:
+			maxRanking = r.Advancement.Level
+		case AdvancementPercent:
+			maxRanking = len(r.Results) * r.Advancement.Level / 100
+		}
+	}
+	maxRanking = min(maxRanking, maxAdvancing)
+
+	var advancing []Result
+	for _, res := range ranked {
+		if res.Ranking > maxRanking {
+			break
+		}
+		if r.Advancement != nil && r.Advancement.Type == AdvancementAttemptResult {
+			result := r.rankingResult(res)
+			if result <= 0 || result >= r.Advancement.Level {
+				continue
+			}
+		}
+		advancing = append(advancing, res)
+	}
+
+	// Competitors tied at the cutoff advance together, unless that would let
+	// more than 75% through. Then none of the tied competitors advance.
+	for len(advancing) > maxAdvancing {
+		last := advancing[len(advancing)-1].Ranking
+		for len(advancing) > 0 && advancing[len(advancing)-1].Ranking == last {
+			advancing = advancing[:len(advancing)-1]
+		}
+	}
+	return advancing
+}
@@ -0,0 +1,74 @@
+package models
+
+import (
+	"slices"
+	"testing"
+)
+
+// ranked returns a result for every ranking, with the person ID being its
+// position and the single and average growing with the ranking. A ranking of
+// 0 has no result posted, a negative ranking no successful attempt.
+func ranked(rankings ...int) []Result {
+	var results []Result
+	for i, ranking := range rankings {
+		res := Result{PersonId: i + 1, Ranking: ranking, Best: ranking * 1000, Average: ranking * 1000}
+		if ranking < 0 {
+			res = Result{PersonId: i + 1, Ranking: -ranking, Best: -1, Average: -1}
+		}
+		if ranking == 0 {
+			res.Best, res.Average = 0, 0
+		}
+		results = append(results, res)
+	}
+	return results
+}
+
+func TestAdvancingResults(t *testing.T) {
+	tests := []struct {
+		name        string
+		format      string
+		advancement *AdvancementCondition
+		results     []Result
+		want        []int
+	}{
+		{"no condition advances 75%", "a", nil, ranked(1, 2, 3, 4, 5, 6, 7, 8), []int{1, 2, 3, 4, 5, 6}},
+		{"no condition rounds down", "a", nil, ranked(1, 2, 3, 4, 5), []int{1, 2, 3}},
+		{"no results", "a", nil, nil, nil},
+		{"results not posted", "a", nil, ranked(1, 2, 0, 0), []int{1, 2}},
+		{"without a successful attempt", "a", &AdvancementCondition{Type: AdvancementRanking, Level: 3}, ranked(1, 2, -3, 4), []int{1, 2}},
+
+		{"ranking", "a", &AdvancementCondition{Type: AdvancementRanking, Level: 3}, ranked(1, 2, 3, 4, 5, 6, 7, 8), []int{1, 2, 3}},
+		{"ranking tied at the cutoff", "a", &AdvancementCondition{Type: AdvancementRanking, Level: 3}, ranked(1, 2, 3, 3, 5, 6, 7, 8), []int{1, 2, 3, 4}},
+		{"ranking capped at 75%", "a", &AdvancementCondition{Type: AdvancementRanking, Level: 8}, ranked(1, 2, 3, 4, 5, 6, 7, 8), []int{1, 2, 3, 4, 5, 6}},
+		{"tie beyond 75% drops the tied", "a", &AdvancementCondition{Type: AdvancementRanking, Level: 8}, ranked(1, 2, 3, 4, 5, 6, 6, 8), []int{1, 2, 3, 4, 5}},
+		{"tie over the whole cutoff", "a", &AdvancementCondition{Type: AdvancementRanking, Level: 2}, ranked(1, 1, 1, 1), nil},
+		{"ordered by ranking", "a", &AdvancementCondition{Type: AdvancementRanking, Level: 2}, ranked(4, 2, 3, 1), []int{4, 2}},
+
+		{"percent", "a", &AdvancementCondition{Type: AdvancementPercent, Level: 50}, ranked(1, 2, 3, 4, 5, 6, 7, 8), []int{1, 2, 3, 4}},
+		{"percent rounds down", "a", &AdvancementCondition{Type: AdvancementPercent, Level: 50}, ranked(1, 2, 3, 4, 5, 6, 7), []int{1, 2, 3}},
+		{"percent capped at 75%", "a", &AdvancementCondition{Type: AdvancementPercent, Level: 100}, ranked(1, 2, 3, 4), []int{1, 2, 3}},
+
+		{"attempt result by average", "a", &AdvancementCondition{Type: AdvancementAttemptResult, Level: 3000}, ranked(1, 2, 3, 4, 5, 6, 7, 8), []int{1, 2}},
+		{"attempt result by single", "1", &AdvancementCondition{Type: AdvancementAttemptResult, Level: 3500}, ranked(1, 2, 3, 4, 5, 6, 7, 8), []int{1, 2, 3}},
+		{"attempt result capped at 75%", "a", &AdvancementCondition{Type: AdvancementAttemptResult, Level: 10000}, ranked(1, 2, 3, 4), []int{1, 2, 3}},
+		{"attempt result without an average", "a", &AdvancementCondition{Type: AdvancementAttemptResult, Level: 3000}, []Result{
+			{PersonId: 1, Ranking: 1, Best: 1000, Average: 1500},
+			{PersonId: 2, Ranking: 2, Best: 900, Average: -1},
+			{PersonId: 3, Ranking: 3, Best: 5000, Average: 6000},
+			{PersonId: 4, Ranking: 4, Best: 6000, Average: 7000},
+		}, []int{1}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := Round{Format: tt.format, Advancement: tt.advancement, Results: tt.results}
+			var got []int
+			for _, res := range r.AdvancingResults() {
+				got = append(got, res.PersonId)
+			}
+			if !slices.Equal(got, tt.want) {
+				t.Errorf("AdvancingResults() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
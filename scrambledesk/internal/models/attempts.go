@@ -0,0 +1,230 @@
+package models
+
+import (
+	"fmt"
+	"regexp"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/larsjohanfolde/scrambleman/scrambledesk/internal/tnoodle"
+)
+
+// Attempt is one attempt of a group in an event scrambled per attempt, like
+// fewest moves and multi-blind. Every attempt has its own scramble set.
+type Attempt struct {
+	Number       int
+	ActivityId   int
+	ActivityCode string
+	StartTime    time.Time
+	EndTime      time.Time
+	Lifecycle
+	SealedPassword string `json:",omitempty"`
+
+	// Deprecated: replaced by SealedPassword, only read to seal the passwords of old competition files
+	Password string `json:",omitempty"`
+}
+
+var attemptSuffix = regexp.MustCompile(`,? Attempt \d+$`)
+
+// ScrambleSet is the name TNoodle gave the scramble set of the attempt
+func (a Attempt) ScrambleSet(g *Group) string {
+	return a.scrambleKey(g).String()
+}
+
+func (a Attempt) scrambleKey(g *Group) tnoodle.Key {
+	key := g.scrambleKey()
+	key.Attempt = a.Number
+	return key
+}
+
+// CurrentScrambleSet is the scramble set the group opens next, that of its
+// current attempt when scrambled per attempt
+func (g *Group) CurrentScrambleSet() string {
+	a := g.activeAttempt()
+	if a == nil {
+		a = g.nextAttempt()
+	}
+	if a == nil {
+		return g.ScrambleSet()
+	}
+	return a.ScrambleSet(g)
+}
+
+// PerAttempt reports whether the round is scrambled per attempt
+func (r *Round) PerAttempt() bool {
+	return len(r.Attempts) > 0
+}
+
+// addAttemptActivity merges an attempt activity like 333fm-r1-a2 into its round.
+// The child activities are the groups doing the attempt.
+func (c *Competition) addAttemptActivity(act wcifActivity, venueID, roomID int) {
+	number, _ := parseAttemptNumber(act.ActivityCode)
+	code := strings.TrimSuffix(act.ActivityCode, fmt.Sprintf("-a%d", number))
+
+	var round *Round
+	for i, r := range c.Rounds {
+		if r.ActivityCode == code && r.RoomID == roomID {
+			round = &c.Rounds[i]
+		}
+	}
+	if round == nil {
+		c.Rounds = append(c.Rounds, Round{
+			ID:           act.ID,
+			EventName:    attemptSuffix.ReplaceAllString(act.Name, ""),
+			EventId:      strings.Split(code, "-")[0],
+			VenueID:      venueID,
+			RoomID:       roomID,
+			ActivityCode: code,
+			StartTime:    act.StartTime,
+			EndTime:      act.EndTime,
+		})
+		round = &c.Rounds[len(c.Rounds)-1]
+	}
+
+	if act.StartTime.Before(round.StartTime) {
+		round.StartTime = act.StartTime
+	}
+	round.EndTime = later(round.EndTime, act.EndTime)
+	round.Attempts = append(round.Attempts, Attempt{
+		Number:       number,
+		ActivityId:   act.ID,
+		ActivityCode: act.ActivityCode,
+		StartTime:    act.StartTime,
+		EndTime:      act.EndTime,
+	})
+
+	for _, child := range act.Groups {
+		groupNumber, ok := parseGroupNumber(child.ActivityCode)
+		if !ok {
+			continue
+		}
+
+		groupCode := fmt.Sprintf("%s-g%d", code, groupNumber)
+		j := slices.IndexFunc(round.Groups, func(g Group) bool { return g.ActivityCode == groupCode })
+		if j < 0 {
+			group := child
+			group.ActivityCode = groupCode
+			group.EventName = attemptSuffix.ReplaceAllString(child.EventName, "")
+			round.Groups = append(round.Groups, group)
+			j = len(round.Groups) - 1
+		}
+
+		round.Groups[j].Attempts = append(round.Groups[j].Attempts, Attempt{
+			Number:     number,
+			ActivityId: child.ActivityId,
+			StartTime:  child.StartTime,
+			EndTime:    child.EndTime,
+		})
+	}
+}
+
+// fillAttempts gives every group of a round scrambled per attempt all of the
+// round's attempts, and spans the group over them
+func (r *Round) fillAttempts() {
+	if !r.PerAttempt() {
+		return
+	}
+	slices.SortFunc(r.Attempts, func(a, b Attempt) int { return a.Number - b.Number })
+
+	for j := range r.Groups {
+		group := &r.Groups[j]
+		for _, template := range r.Attempts {
+			if !slices.ContainsFunc(group.Attempts, func(a Attempt) bool { return a.Number == template.Number }) {
+				group.Attempts = append(group.Attempts, template)
+			}
+		}
+		slices.SortFunc(group.Attempts, func(a, b Attempt) int { return a.Number - b.Number })
+
+		for k := range group.Attempts {
+			a := &group.Attempts[k]
+			a.ActivityCode = fmt.Sprintf("%s-a%d", group.ActivityCode, a.Number)
+			if a.State == "" {
+				a.State = StatePending
+			}
+		}
+		group.StartTime = group.Attempts[0].StartTime
+		group.EndTime = group.Attempts[len(group.Attempts)-1].EndTime
+	}
+}
+
+// hasActivity reports whether the WCIF activity is the group or one of its attempts
+func (g *Group) hasActivity(id int) bool {
+	if id == 0 {
+		return false
+	}
+	if id == g.ActivityId {
+		return true
+	}
+	return slices.ContainsFunc(g.Attempts, func(a Attempt) bool { return a.ActivityId == id })
+}
+
+// activeAttempt returns the attempt on the floor, if any
+func (g *Group) activeAttempt() *Attempt {
+	for i, a := range g.Attempts {
+		if a.Active() {
+			return &g.Attempts[i]
+		}
+	}
+	return nil
+}
+
+// nextAttempt returns the first pending attempt, if any
+func (g *Group) nextAttempt() *Attempt {
+	for i, a := range g.Attempts {
+		if a.state() == StatePending {
+			return &g.Attempts[i]
+		}
+	}
+	return nil
+}
+
+// closeAttempt closes the attempt on the floor. Attempts not run yet stay
+// pending, they are scheduled on their own.
+func (g *Group) closeAttempt(at time.Time) error {
+	a := g.activeAttempt()
+	if a == nil {
+		return nil
+	}
+	return a.transition(a.ActivityCode, StateClosed, at)
+}
+
+// settleAttempts moves a group scrambled per attempt to the state of its
+// attempts after they were forced. Between its attempts the group is closed.
+func (g *Group) settleAttempts(at time.Time) {
+	switch {
+	case slices.ContainsFunc(g.Attempts, func(a Attempt) bool { return a.state() == StateClosed }):
+		g.force(StateClosed, at)
+	case slices.ContainsFunc(g.Attempts, func(a Attempt) bool { return a.state() == StatePending }):
+		g.force(StatePending, at)
+	default:
+		g.force(StateSkipped, at)
+	}
+}
+
+// Done reports whether the group is closed or skipped, and so are its attempts
+func (g *Group) Done() bool {
+	if !g.Lifecycle.Done() {
+		return false
+	}
+	for _, a := range g.Attempts {
+		if !a.Done() {
+			return false
+		}
+	}
+	return true
+}
+
+// findAttempt finds an attempt by its activity code, like 333fm-r1-g1-a2
+func (c *Competition) findAttempt(activityCode string) (*Group, *Attempt) {
+	for i, r := range c.Rounds {
+		for j, g := range r.Groups {
+			for k, a := range g.Attempts {
+				if a.ActivityCode == activityCode {
+					return &c.Rounds[i].Groups[j], &c.Rounds[i].Groups[j].Attempts[k]
+				}
+			}
+		}
+	}
+	return nil, nil
+}
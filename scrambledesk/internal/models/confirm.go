@@ -0,0 +1,136 @@
+package models
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+	"sync"
+	"time"
+)
+
+// DefaultMaxEarly is how long before its planned start a group can be opened without an extra confirmation
+const DefaultMaxEarly = 15 * time.Minute
+
+var (
+	ErrPromptPending = errors.New("another confirmation is pending")
+	ErrNoPrompt      = errors.New("no such confirmation pending")
+)
+
+// Confirmer asks the operator to confirm an action before it is carried out
+type Confirmer interface {
+	Confirm(prompt string) (bool, error)
+}
+
+// StdinConfirmer asks on the terminal
+type StdinConfirmer struct {
+	out    io.Writer
+	reader *bufio.Reader
+}
+
+func NewStdinConfirmer() *StdinConfirmer {
+	return &StdinConfirmer{out: os.Stdout, reader: bufio.NewReader(os.Stdin)}
+}
+
+func (s *StdinConfirmer) Confirm(prompt string) (bool, error) {
+	fmt.Fprintf(s.out, "%s (y/N): ", prompt)
+	input, err := s.reader.ReadString('\n')
+	if err != nil && input == "" {
+		return false, fmt.Errorf("could not read input: %w", err)
+	}
+
+	input = strings.TrimSpace(strings.ToLower(input))
+	return input == "y" || input == "yes", nil
+}
+
+// AlwaysYes confirms everything, for scripts and unattended use. Groups more
+// than the maximum ahead of their planned start are refused rather than confirmed.
+type AlwaysYes struct{}
+
+func (AlwaysYes) Confirm(string) (bool, error) {
+	return true, nil
+}
+
+func (AlwaysYes) unattended() {}
+
+// unattended is implemented by confirmers nobody is watching
+type unattended interface {
+	unattended()
+}
+
+type Prompt struct {
+	ID      int
+	Message string
+}
+
+// RemoteConfirmer hands the prompt to another part of the program, like a web
+// page or a foot pedal, and waits for it to be answered with Answer. Prompts
+// not answered within the timeout are declined.
+type RemoteConfirmer struct {
+	Timeout time.Duration
+
+	mu      sync.Mutex
+	nextID  int
+	pending *Prompt
+	reply   chan bool
+}
+
+func NewRemoteConfirmer(timeout time.Duration) *RemoteConfirmer {
+	return &RemoteConfirmer{Timeout: timeout}
+}
+
+func (r *RemoteConfirmer) Confirm(prompt string) (bool, error) {
+	r.mu.Lock()
+	if r.pending != nil {
+		r.mu.Unlock()
+		return false, ErrPromptPending
+	}
+	r.nextID++
+	r.pending = &Prompt{ID: r.nextID, Message: strings.TrimSpace(prompt)}
+	reply := make(chan bool, 1)
+	r.reply = reply
+	r.mu.Unlock()
+
+	defer func() {
+		r.mu.Lock()
+		r.pending = nil
+		r.reply = nil
+		r.mu.Unlock()
+	}()
+
+	timer := time.NewTimer(r.Timeout)
+	defer timer.Stop()
+
+	select {
+	case yes := <-reply:
+		return yes, nil
+	case <-timer.C:
+		return false, nil
+	}
+}
+
+// Pending returns the prompt waiting for an answer, if any
+func (r *RemoteConfirmer) Pending() (Prompt, bool) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if r.pending == nil {
+		return Prompt{}, false
+	}
+	return *r.pending, true
+}
+
+// Answer replies to the prompt with the given ID
+func (r *RemoteConfirmer) Answer(id int, yes bool) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if r.pending == nil || r.pending.ID != id {
+		return ErrNoPrompt
+	}
+	r.reply <- yes
+	r.pending = nil
+	return nil
+}
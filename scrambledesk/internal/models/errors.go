@@ -0,0 +1,21 @@
+package models
+
+import "errors"
+
+var (
+	ErrPasswordMissing     = errors.New("password missing")
+	ErrPasscodeUnmatched   = errors.New("passcode without a group")
+	ErrScrambleSetNotFound = errors.New("scramble set not found")
+	ErrNoScrambleSets      = errors.New("no computer display PDFs found")
+	ErrScrambleSetCount    = errors.New("scramble set count mismatch")
+	ErrZipEncrypted        = errors.New("zip is password protected")
+	ErrNoUpcomingGroup     = errors.New("no upcoming group")
+	ErrNoOpenGroup         = errors.New("no group has its scrambles open")
+	ErrIllegalTransition   = errors.New("illegal transition")
+	ErrCancelled           = errors.New("cancelled")
+	ErrTooEarly            = errors.New("too early to open")
+	ErrNothingToUndo       = errors.New("nothing to undo")
+	ErrBackupNotFound      = errors.New("backup not found")
+	ErrNoPassphrase        = errors.New("no passphrase given")
+	ErrWrongPassphrase     = errors.New("wrong passphrase")
+)
@@ -0,0 +1,195 @@
+package models
+
+import (
+	"fmt"
+	"slices"
+	"time"
+)
+
+// State is where a group is in its lifecycle
+type State string
+
+const (
+	StatePending       State = "pending"
+	StateScramblesOpen State = "scrambles-open"
+	StateHandIn        State = "hand-in"
+	StateClosed        State = "closed"
+	StateSkipped       State = "skipped"
+)
+
+// transitions lists the states each state can move to
+var transitions = map[State][]State{
+	StatePending:       {StateScramblesOpen, StateSkipped},
+	StateScramblesOpen: {StateHandIn, StateClosed},
+	StateHandIn:        {StateClosed, StateScramblesOpen},
+	StateClosed:        {StateScramblesOpen},
+	StateSkipped:       {StatePending, StateScramblesOpen},
+}
+
+// Transition is a recorded change of state. Forced transitions were made by
+// -start-from and did not have to follow the lifecycle.
+type Transition struct {
+	From   State
+	To     State
+	Time   time.Time
+	Forced bool `json:",omitempty"`
+}
+
+// TransitionError is returned when a group is asked to make a move its lifecycle does not allow
+type TransitionError struct {
+	Group string
+	From  State
+	To    State
+}
+
+func (e *TransitionError) Error() string {
+	return fmt.Sprintf("%s cannot go from %s to %s", e.Group, e.From, e.To)
+}
+
+func (e *TransitionError) Unwrap() error {
+	return ErrIllegalTransition
+}
+
+// Lifecycle holds the state of a group and how it got there
+type Lifecycle struct {
+	State       State
+	Transitions []Transition
+}
+
+// CanTransition reports whether the state can move to the given state
+func (l *Lifecycle) CanTransition(to State) bool {
+	return slices.Contains(transitions[l.state()], to)
+}
+
+func (l *Lifecycle) transition(name string, to State, at time.Time) error {
+	if !l.CanTransition(to) {
+		return &TransitionError{Group: name, From: l.state(), To: to}
+	}
+	l.Transitions = append(l.Transitions, Transition{From: l.state(), To: to, Time: at})
+	l.State = to
+	return nil
+}
+
+// force moves to the state without checking the lifecycle
+func (l *Lifecycle) force(to State, at time.Time) {
+	if l.state() == to {
+		return
+	}
+	l.Transitions = append(l.Transitions, Transition{From: l.state(), To: to, Time: at, Forced: true})
+	l.State = to
+}
+
+func (l *Lifecycle) state() State {
+	if l.State == "" {
+		return StatePending
+	}
+	return l.State
+}
+
+// Active reports whether the group is on the floor, with its scrambles open or handing in
+func (l *Lifecycle) Active() bool {
+	return l.state() == StateScramblesOpen || l.state() == StateHandIn
+}
+
+// Done reports whether the group is closed or skipped
+func (l *Lifecycle) Done() bool {
+	return l.state() == StateClosed || l.state() == StateSkipped
+}
+
+// OpenedAt is when the scrambles were last opened, zero if they never were
+func (l *Lifecycle) OpenedAt() time.Time {
+	for i := len(l.Transitions) - 1; i >= 0; i-- {
+		if l.Transitions[i].To == StateScramblesOpen {
+			return l.Transitions[i].Time
+		}
+	}
+	return time.Time{}
+}
+
+// ClosedAt is when the scrambles were last closed, zero if they are open or never were
+func (l *Lifecycle) ClosedAt() time.Time {
+	for i := len(l.Transitions) - 1; i >= 0; i-- {
+		t := l.Transitions[i]
+		if t.To == StateScramblesOpen {
+			return time.Time{}
+		}
+		if t.From == StateScramblesOpen {
+			return t.Time
+		}
+	}
+	return time.Time{}
+}
+
+// Done reports whether every group of the round is closed or skipped
+func (r *Round) Done() bool {
+	for _, g := range r.Groups {
+		if !g.Done() {
+			return false
+		}
+	}
+	return true
+}
+
+// activeGroups returns the groups in the managed rooms that are on the floor
+func (c *Competition) activeGroups() []*Group {
+	var groups []*Group
+	for i, r := range c.Rounds {
+		if !c.roomSelected(r) {
+			continue
+		}
+		for j, g := range r.Groups {
+			if g.Active() {
+				groups = append(groups, &c.Rounds[i].Groups[j])
+			}
+		}
+	}
+	return groups
+}
+
+// migrate converts groups saved before the lifecycle was introduced. The old
+// closed timestamps were recorded on the wrong group and are dropped, as is the
+// operation log which cannot be reverted.
+func (c *Competition) migrate() {
+	var latest *Group
+	migrated := false
+	for i, r := range c.Rounds {
+		for j, g := range r.Groups {
+			group := &c.Rounds[i].Groups[j]
+			if g.State == "" {
+				group.State = StatePending
+			}
+			if !g.Opened && !g.Finished && len(g.OpenedTimestamp) == 0 && len(g.ClosedTimestamp) == 0 {
+				continue
+			}
+			migrated = true
+
+			for _, t := range g.OpenedTimestamp {
+				group.Transitions = append(group.Transitions, Transition{From: group.state(), To: StateScramblesOpen, Time: t})
+				group.State = StateScramblesOpen
+			}
+
+			switch {
+			case g.Opened:
+				group.State = StateClosed
+				if latest == nil || group.OpenedAt().After(latest.OpenedAt()) {
+					latest = group
+				}
+			case g.Finished:
+				group.State = StateSkipped
+			}
+
+			group.Opened = false
+			group.Finished = false
+			group.OpenedTimestamp = nil
+			group.ClosedTimestamp = nil
+		}
+	}
+
+	if !migrated {
+		return
+	}
+	if latest != nil {
+		latest.State = StateScramblesOpen
+	}
+	c.Operations = nil
+}
@@ -0,0 +1,136 @@
+package models
+
+import (
+	"errors"
+	"testing"
+	"time"
+)
+
+func TestLifecycleTransition(t *testing.T) {
+	tests := []struct {
+		from State
+		to   State
+		ok   bool
+	}{
+		{"", StateScramblesOpen, true},
+		{"", StateClosed, false},
+		{StatePending, StateScramblesOpen, true},
+		{StatePending, StateSkipped, true},
+		{StatePending, StateHandIn, false},
+		{StatePending, StateClosed, false},
+		{StatePending, StatePending, false},
+		{StateScramblesOpen, StateHandIn, true},
+		{StateScramblesOpen, StateClosed, true},
+		{StateScramblesOpen, StatePending, false},
+		{StateScramblesOpen, StateSkipped, false},
+		{StateScramblesOpen, StateScramblesOpen, false},
+		{StateHandIn, StateClosed, true},
+		{StateHandIn, StateScramblesOpen, true},
+		{StateHandIn, StateSkipped, false},
+		{StateClosed, StateScramblesOpen, true},
+		{StateClosed, StatePending, false},
+		{StateClosed, StateHandIn, false},
+		{StateSkipped, StatePending, true},
+		{StateSkipped, StateScramblesOpen, true},
+		{StateSkipped, StateClosed, false},
+		{"unknown", StateScramblesOpen, false},
+	}
+
+	at := time.Date(2026, 5, 2, 9, 0, 0, 0, time.UTC)
+	for _, tt := range tests {
+		t.Run(string(tt.from)+"->"+string(tt.to), func(t *testing.T) {
+			l := Lifecycle{State: tt.from}
+			if l.CanTransition(tt.to) != tt.ok {
+				t.Errorf("CanTransition(%s) = %t, want %t", tt.to, !tt.ok, tt.ok)
+			}
+
+			err := l.transition("333-r1-g1", tt.to, at)
+			if !tt.ok {
+				var te *TransitionError
+				if !errors.As(err, &te) || !errors.Is(err, ErrIllegalTransition) {
+					t.Fatalf("transition() error = %v, want a TransitionError", err)
+				}
+				if l.State != tt.from || len(l.Transitions) != 0 {
+					t.Errorf("a refused transition changed the lifecycle to %+v", l)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("transition() error = %v", err)
+			}
+			// Groups without a state are pending
+			from := (&Lifecycle{State: tt.from}).state()
+			want := Transition{From: from, To: tt.to, Time: at}
+			if l.State != tt.to || len(l.Transitions) != 1 || l.Transitions[0] != want {
+				t.Errorf("lifecycle is %+v after the transition, want state %s and %+v", l, tt.to, want)
+			}
+		})
+	}
+}
+
+func TestLifecycleForce(t *testing.T) {
+	at := time.Date(2026, 5, 2, 9, 0, 0, 0, time.UTC)
+	l := Lifecycle{State: StatePending}
+	l.force(StateClosed, at)
+	if l.State != StateClosed || len(l.Transitions) != 1 || !l.Transitions[0].Forced {
+		t.Errorf("force() = %+v, want a forced move to %s", l, StateClosed)
+	}
+
+	// Forcing the current state records nothing
+	l.force(StateClosed, at)
+	if len(l.Transitions) != 1 {
+		t.Errorf("forcing the current state recorded %d transitions, want 1", len(l.Transitions))
+	}
+}
+
+func TestLifecycleTimes(t *testing.T) {
+	t0 := time.Date(2026, 5, 2, 9, 0, 0, 0, time.UTC)
+	at := func(minutes int) time.Time { return t0.Add(time.Duration(minutes) * time.Minute) }
+
+	tests := []struct {
+		name       string
+		moves      []State
+		state      State
+		active     bool
+		done       bool
+		wantOpened time.Time
+		wantClosed time.Time
+	}{
+		{"pending", nil, StatePending, false, false, time.Time{}, time.Time{}},
+		{"skipped", []State{StateSkipped}, StateSkipped, false, true, time.Time{}, time.Time{}},
+		{"open", []State{StateScramblesOpen}, StateScramblesOpen, true, false, at(1), time.Time{}},
+		{"handing in", []State{StateScramblesOpen, StateHandIn}, StateHandIn, true, false, at(1), at(2)},
+		{"closed", []State{StateScramblesOpen, StateClosed}, StateClosed, false, true, at(1), at(2)},
+		{"closed after hand-in", []State{StateScramblesOpen, StateHandIn, StateClosed}, StateClosed, false, true, at(1), at(2)},
+		{"reopened", []State{StateScramblesOpen, StateClosed, StateScramblesOpen}, StateScramblesOpen, true, false, at(3), time.Time{}},
+		{"closed again", []State{StateScramblesOpen, StateClosed, StateScramblesOpen, StateClosed}, StateClosed, false, true, at(3), at(4)},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var l Lifecycle
+			for i, to := range tt.moves {
+				err := l.transition("333-r1-g1", to, at(i+1))
+				if err != nil {
+					t.Fatal(err)
+				}
+			}
+
+			if l.state() != tt.state {
+				t.Errorf("state = %s, want %s", l.state(), tt.state)
+			}
+			if l.Active() != tt.active {
+				t.Errorf("Active() = %t, want %t", l.Active(), tt.active)
+			}
+			if l.Done() != tt.done {
+				t.Errorf("Done() = %t, want %t", l.Done(), tt.done)
+			}
+			if !l.OpenedAt().Equal(tt.wantOpened) {
+				t.Errorf("OpenedAt() = %v, want %v", l.OpenedAt(), tt.wantOpened)
+			}
+			if !l.ClosedAt().Equal(tt.wantClosed) {
+				t.Errorf("ClosedAt() = %v, want %v", l.ClosedAt(), tt.wantClosed)
+			}
+		})
+	}
+}
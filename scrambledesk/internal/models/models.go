@@ -1,32 +1,49 @@
 package models
 
 import (
-	"bufio"
 	"encoding/json"
 	"errors"
 	"fmt"
 	"io"
-	"log"
 	"math"
 	"net/http"
 	"os"
 	"os/exec"
 	"path"
 	"path/filepath"
+	"slices"
 	"sort"
+	"strconv"
 	"strings"
 	"time"
 
-	"github.com/phpdave11/gofpdf"
 	"github.com/larsjohanfolde/scrambleman/scrambledesk/config"
+	"github.com/larsjohanfolde/scrambleman/scrambledesk/internal/audit"
 	"github.com/larsjohanfolde/scrambleman/scrambledesk/internal/pdf"
+	"github.com/larsjohanfolde/scrambleman/scrambledesk/internal/tnoodle"
+	"github.com/phpdave11/gofpdf"
 )
 
 type Competition struct {
-	ID      string
-	Name    string
-	Rounds  []Round
-	Persons []Person
+	ID            string
+	Name          string
+	Rooms         []Room
+	SelectedRooms []int
+	Rounds        []Round
+	Persons       []Person
+	Display       DisplayState
+	Operations    []Operation
+	Sealing       *Sealing `json:",omitempty"`
+	Watermark     Watermark
+	// Passcodes is what did not match when the passwords were last loaded
+	Passcodes PasscodeReport
+	// AuditAnchor is the last entry this competition recorded in the audit log
+	AuditAnchor audit.Anchor
+
+	// key seals the scramble passwords, derived from the passphrase by Unlock
+	key []byte
+	// pendingAudit are the changes of state recorded in the audit log once they are saved
+	pendingAudit []auditEntry
 }
 
 type Round struct {
@@ -34,36 +51,65 @@ type Round struct {
 	EventName    string
 	ActivityCode string
 	EventId      string
+	VenueID      int
+	RoomID       int
 	RoundNumber  int
 	GroupCount   int
-	Groups       []Group
-	Finished     bool
-	Results      []Result
-	StartTime    time.Time
-	EndTime      time.Time
+	// ScrambleSetCount is the number of scramble sets the WCIF asks for
+	ScrambleSetCount int
+	Groups           []Group
+	Format           string
+	Advancement      *AdvancementCondition
+	GroupSource      GroupSource
+	// Attempts of rounds scrambled per attempt, copied to each group
+	Attempts  []Attempt
+	Results   []Result
+	StartTime time.Time
+	EndTime   time.Time
 }
 
+// GroupSource tells where the competitors of an advanced round's groups came from
+type GroupSource string
+
+const (
+	GroupSourceWCIF     GroupSource = "wcif"
+	GroupSourceComputed GroupSource = "computed"
+)
+
 type Result struct {
 	PersonId int
 	Ranking  int
+	Best     int
+	Average  int
 }
 
 type Group struct {
-	ActivityId      int    `json:"id"`
-	ActivityCode    string `json:"activityCode"`
-	EventName       string `json:"name"`
-	EventId         string
-	StartTime       time.Time
-	EndTime         time.Time
-	RoundNumber     int
-	GroupNumber     int
-	Opened          bool
-	OpenedTimestamp []time.Time
-	ClosedTimestamp []time.Time
-	Finished        bool
-	Competitors     []Person
-	Staff           []Person
-	Password        string
+	ActivityId   int    `json:"id"`
+	ActivityCode string `json:"activityCode"`
+	EventName    string `json:"name"`
+	EventId      string
+	StartTime    time.Time
+	EndTime      time.Time
+	RoundNumber  int
+	GroupNumber  int
+	Lifecycle
+	Competitors []Person
+	Staff       []Person
+	Judges      []Person
+	Scramblers  []Person
+	Runners     []Person
+	DataEntry   []Person
+	// SealedPassword is the password of the scramble set, sealed by the passphrase
+	SealedPassword string `json:",omitempty"`
+	Attempts       []Attempt
+
+	// Deprecated: replaced by SealedPassword, only read to seal the passwords of old competition files
+	Password string `json:",omitempty"`
+	// Deprecated: replaced by Lifecycle, only read to migrate old competition files
+	Opened          bool        `json:",omitempty"`
+	Finished        bool        `json:",omitempty"`
+	OpenedTimestamp []time.Time `json:",omitempty"`
+	ClosedTimestamp []time.Time `json:",omitempty"`
 }
 
 type Person struct {
@@ -75,6 +121,16 @@ type Person struct {
 	Assignments []Assignment
 }
 
+// simplified strips the assignments and avatar, which groups don't need to keep
+func (p Person) simplified() Person {
+	return Person{
+		ID:    p.ID,
+		Name:  p.Name,
+		WcaId: p.WcaId,
+		Roles: p.Roles,
+	}
+}
+
 type Assignment struct {
 	ActivityId     int
 	StationNumber  int
@@ -86,6 +142,14 @@ type Avatar struct {
 	ThumbUrl string
 }
 
+// addPerson appends the person unless they are already in the list
+func addPerson(persons []Person, p Person) []Person {
+	if slices.ContainsFunc(persons, func(q Person) bool { return q.ID == p.ID }) {
+		return persons
+	}
+	return append(persons, p)
+}
+
 func (r *Round) Competitors() []Person {
 	var competitors []Person
 	for _, g := range r.Groups {
@@ -94,41 +158,197 @@ func (r *Round) Competitors() []Person {
 	return competitors
 }
 
+// OpenScrambleSet opens the scrambles of a group, or of an attempt like
+// 333fm-r1-g1-a2. A group scrambled per attempt opens its current attempt.
 func (c *Competition) OpenScrambleSet(activityCode string) error {
-	for i, r := range c.Rounds {
-		for j, g := range r.Groups {
-			if g.ActivityCode == activityCode {
-				// NOTE: We call SendPDF() on the real group as this modifies the underlying data
-				err := c.Rounds[i].Groups[j].SendPDF(c.Name)
-				if err != nil {
-					return err
-				}
-				return nil
+	_, group := c.findGroup(activityCode)
+	var attempt *Attempt
+	if group == nil {
+		group, attempt = c.findAttempt(activityCode)
+	} else if len(group.Attempts) > 0 {
+		attempt = group.activeAttempt()
+		if attempt == nil {
+			attempt = group.nextAttempt()
+		}
+		if attempt == nil {
+			return fmt.Errorf("%s has no attempts left to open", activityCode)
+		}
+	}
+	if group == nil {
+		return fmt.Errorf("%w: %s", ErrScrambleSetNotFound, activityCode)
+	}
+
+	err := canOpen(group, attempt)
+	if err != nil {
+		return err
+	}
+	op := c.beginOperation(OpOpen, group)
+
+	// The watermark shows the time the scrambles are recorded as opened
+	now := time.Now()
+	scramble := group.ActivityCode
+	if attempt != nil {
+		scramble = attempt.ActivityCode
+		err = attempt.SendPDF(c, group, now)
+	} else {
+		err = group.SendPDF(c, now)
+	}
+	if err != nil {
+		return err
+	}
+
+	err = openScrambles(group, attempt, now)
+	if err != nil {
+		return err
+	}
+	c.auditOnSave(audit.ActionOpen, scramble, "")
+
+	c.Display.Scramble = scramble
+	c.recordOperation(op)
+	return nil
+}
+
+// canOpen checks that the scrambles of the group, and the attempt if given, can be opened
+func canOpen(group *Group, attempt *Attempt) error {
+	if group.state() != StateScramblesOpen && !group.CanTransition(StateScramblesOpen) {
+		return &TransitionError{Group: group.ActivityCode, From: group.state(), To: StateScramblesOpen}
+	}
+	if attempt != nil && attempt.state() != StateScramblesOpen && !attempt.CanTransition(StateScramblesOpen) {
+		return &TransitionError{Group: attempt.ActivityCode, From: attempt.state(), To: StateScramblesOpen}
+	}
+	return nil
+}
+
+// openScrambles moves the group, and the attempt if given, to scrambles open.
+// Another attempt of the group on the floor is closed, scrambles already open stay open.
+func openScrambles(group *Group, attempt *Attempt, at time.Time) error {
+	if group.state() != StateScramblesOpen {
+		err := group.transition(group.ActivityCode, StateScramblesOpen, at)
+		if err != nil {
+			return err
+		}
+	}
+	if attempt == nil {
+		return nil
+	}
+
+	for i, a := range group.Attempts {
+		if a.ActivityCode != attempt.ActivityCode && a.Active() {
+			err := group.Attempts[i].transition(a.ActivityCode, StateClosed, at)
+			if err != nil {
+				return err
 			}
 		}
 	}
-	return errors.New("scramble set not found")
+	if attempt.state() == StateScramblesOpen {
+		return nil
+	}
+	return attempt.transition(attempt.ActivityCode, StateScramblesOpen, at)
+}
+
+// OpenHandIn closes the scrambles of the open group and calls the competitors
+// of the next group to hand in their puzzles
+func (c *Competition) OpenHandIn() error {
+	current := c.OpenGroup()
+	if current == nil {
+		return ErrNoOpenGroup
+	}
+	if !current.CanTransition(StateHandIn) {
+		return &TransitionError{Group: current.ActivityCode, From: current.state(), To: StateHandIn}
+	}
+	attempt := current.activeAttempt()
+	if attempt != nil && !attempt.CanTransition(StateHandIn) {
+		return &TransitionError{Group: attempt.ActivityCode, From: attempt.state(), To: StateHandIn}
+	}
+
+	next := c.NextGroup()
+	op := c.beginOperation(OpHandIn, current)
+
+	if next != nil {
+		// Ensure we have the competitors for the advanced rounds
+		if next.RoundNumber > 1 {
+			err := c.AssignAdvancedRoundCompetitors()
+			if err != nil {
+				return err
+			}
+		}
+
+		err := next.DrawHandInPDF()
+		if err != nil {
+			return err
+		}
+	}
+
+	err := sendFile(intermissionPDF(), config.ScrambleURL)
+	if err != nil {
+		return err
+	}
+
+	now := time.Now()
+	err = current.transition(current.ActivityCode, StateHandIn, now)
+	if err != nil {
+		return err
+	}
+	if attempt != nil {
+		err = attempt.transition(attempt.ActivityCode, StateHandIn, now)
+		if err != nil {
+			return err
+		}
+		c.auditOnSave(audit.ActionHandIn, attempt.ActivityCode, "")
+	} else {
+		c.auditOnSave(audit.ActionHandIn, current.ActivityCode, "")
+	}
+
+	c.Display = DisplayState{Screen: ScreenHandIn}
+	if next != nil {
+		err = sendFile("profiles.pdf", config.CompetitorListURL)
+		if err != nil {
+			return err
+		}
+		c.Display.Group = next.ActivityCode
+	}
+
+	c.recordOperation(op)
+	return nil
 }
 
+// StartFrom makes the given group, or attempt, the next one. Earlier groups and
+// attempts that were not run are skipped, those on the floor are closed and
+// later ones are reset to pending. This overrides the lifecycle, the forced
+// transitions are recorded.
 func (c *Competition) StartFrom(activityCode string) error {
 	c.SortRounds()
-	for i, r := range c.Rounds {
-		for j := range r.Groups {
-			c.Rounds[i].Groups[j].Finished = false
+	slots := c.timeline()
+	target := slices.IndexFunc(slots, func(s slot) bool { return s.matches(activityCode) })
+	if target < 0 {
+		_, group := c.findGroup(activityCode)
+		_, attempt := c.findAttempt(activityCode)
+		if group == nil && attempt == nil {
+			return fmt.Errorf("%w: %s", ErrScrambleSetNotFound, activityCode)
+		}
+		return fmt.Errorf("%s is not in the selected rooms", activityCode)
+	}
+
+	now := time.Now()
+	for i, s := range slots {
+		l := s.lifecycle()
+		switch {
+		case i >= target:
+			l.force(StatePending, now)
+		case l.Active():
+			l.force(StateClosed, now)
+		case !l.Done():
+			l.force(StateSkipped, now)
 		}
-		c.Rounds[i].Finished = false
 	}
-
-	for i, r := range c.Rounds {
-		for j, g := range r.Groups {
-			if g.ActivityCode == activityCode {
-				return nil
-			}
-			c.Rounds[i].Groups[j].Finished = true
+	for _, s := range slots {
+		if s.attempt != nil {
+			s.group.settleAttempts(now)
 		}
-		c.Rounds[i].Finished = true
 	}
-	return errors.New("scramble set not found")
+
+	c.auditOnSave(audit.ActionStartFrom, activityCode, "")
+	return nil
 }
 
 func (c *Competition) AssignCompetitors() {
@@ -138,16 +358,9 @@ func (c *Competition) AssignCompetitors() {
 
 			for _, person := range c.Persons {
 				for _, assign := range person.Assignments {
-					if assign.ActivityId == group.ActivityId {
-						simplified := Person{
-							ID:    person.ID,
-							Name:  person.Name,
-							WcaId: person.WcaId,
-							Roles: person.Roles,
-						}
-
-						if assign.AssignmentCode == "competitor" {
-							group.Competitors = append(group.Competitors, simplified)
+					if group.hasActivity(assign.ActivityId) {
+						if assign.AssignmentCode == AssignmentCompetitor {
+							group.Competitors = addPerson(group.Competitors, person.simplified())
 						}
 					}
 				}
@@ -156,18 +369,40 @@ func (c *Competition) AssignCompetitors() {
 	}
 }
 
+// NextGroup returns the group scheduled next in the managed rooms
 func (c *Competition) NextGroup() *Group {
-	for i, r := range c.Rounds {
-		if r.Finished {
-			continue
-		}
-		for j, g := range r.Groups {
-			if !g.Finished {
-				return &c.Rounds[i].Groups[j]
-			}
+	group, _ := c.Next()
+	return group
+}
+
+// Next returns the group scheduled next in the managed rooms, and the attempt
+// when the group is scrambled per attempt
+func (c *Competition) Next() (*Group, *Attempt) {
+	s, ok := c.nextSlot()
+	if !ok {
+		return nil, nil
+	}
+	return s.group, s.attempt
+}
+
+// CurrentGroup returns the group on the floor that was opened most recently
+func (c *Competition) CurrentGroup() *Group {
+	var current *Group
+	for _, g := range c.activeGroups() {
+		if current == nil || g.OpenedAt().After(current.OpenedAt()) {
+			current = g
 		}
 	}
-	return nil
+	return current
+}
+
+// OpenGroup returns the group whose scrambles are open, if any
+func (c *Competition) OpenGroup() *Group {
+	current := c.CurrentGroup()
+	if current == nil || current.State != StateScramblesOpen {
+		return nil
+	}
+	return current
 }
 
 func (c *Competition) prevRound(r Round) *Round {
@@ -182,38 +417,63 @@ func (c *Competition) prevRound(r Round) *Round {
 	return nil
 }
 
-func (c *Competition) LoadResults() {
+// LoadResults fetches the latest results and group assignments from the WCIF
+func (c *Competition) LoadResults() error {
 	var raw struct {
-		Events []wcifEvent `json:"events"`
+		Events  []wcifEvent `json:"events"`
+		Persons []Person    `json:"persons"`
 	}
 
 	data, err := FetchWCIF(c.ID)
 	if err != nil {
-		log.Fatal(err)
+		return err
 	}
 
 	err = json.Unmarshal(data, &raw)
 	if err != nil {
-		log.Fatalf("Could not unmarshal data: %v", err)
+		return fmt.Errorf("could not unmarshal WCIF: %w", err)
+	}
+
+	c.applyEvents(raw.Events)
+	if raw.Persons != nil {
+		c.Persons = raw.Persons
 	}
+	return nil
+}
 
-	resultMap := make(map[string][]Result)
+// applyEvents copies the results and round settings from the WCIF events onto the rounds
+func (c *Competition) applyEvents(events []wcifEvent) {
+	roundMap := make(map[string]wcifRound)
 
-	for _, e := range raw.Events {
+	for _, e := range events {
 		for _, r := range e.Rounds {
-			resultMap[r.ID] = r.Results
+			roundMap[r.ID] = r
 		}
 	}
 
 	for i, r := range c.Rounds {
-		c.Rounds[i].Results = resultMap[r.ActivityCode]
+		wr := roundMap[r.ActivityCode]
+		c.Rounds[i].Results = wr.Results
+		c.Rounds[i].Format = wr.Format
+		c.Rounds[i].ScrambleSetCount = wr.ScrambleSetCount
+		c.Rounds[i].Advancement = wr.AdvancementCondition
 	}
-
 }
 
-func (c *Competition) AssignAdvancedRoundCompetitors() {
-	c.LoadResults()
-	for i, r := range c.Rounds {
+func (c *Competition) AssignAdvancedRoundCompetitors() error {
+	err := c.LoadResults()
+	if err != nil {
+		return fmt.Errorf("could not load results: %w", err)
+	}
+
+	competitorMap := make(map[int]Person)
+	for _, p := range c.Persons {
+		competitorMap[p.ID] = p
+	}
+
+	// Rounds split across rooms share their competitors, only assign them once
+	assigned := make(map[string]bool)
+	for _, r := range c.Rounds {
 		// Skip initial rounds
 		if r.RoundNumber < 2 {
 			continue
@@ -224,23 +484,40 @@ func (c *Competition) AssignAdvancedRoundCompetitors() {
 			continue
 		}
 
-		competitorMap := make(map[int]Person)
-		for _, p := range c.Persons {
-			competitorMap[p.ID] = p
+		if assigned[r.ActivityCode] {
+			continue
+		}
+		assigned[r.ActivityCode] = true
+
+		// Groups published in the WCIF take precedence over our own
+		rounds := c.roundRooms(r.ActivityCode)
+		published := false
+		for _, rr := range rounds {
+			if c.assignPublishedGroups(rr) {
+				published = true
+			}
+		}
+		source := GroupSourceComputed
+		if published {
+			source = GroupSourceWCIF
+		}
+		for _, rr := range rounds {
+			rr.GroupSource = source
+		}
+		if published {
+			logf("%s: Using groups from the WCIF", r.ActivityCode)
+			continue
+		}
+		logf("%s: No groups in the WCIF, computing groups from results", r.ActivityCode)
+
+		prev := c.prevRound(r)
+		if prev == nil {
+			continue
 		}
 
 		var competitors []Person
 
-		// TODO: Let's not hard code the advancement conditions, no?
-		var results []Result
-		allResults := c.prevRound(r).Results
-		sort.Slice(allResults, func(i, j int) bool {
-			return allResults[i].Ranking < allResults[j].Ranking
-		})
-		roundCompetitorCount := int(float64(len(allResults)) * 0.75)
-		for i := range roundCompetitorCount {
-			results = append(results, allResults[i])
-		}
+		results := prev.AdvancingResults()
 		sort.Slice(results, func(i, j int) bool {
 			return results[i].Ranking > results[j].Ranking
 		})
@@ -248,12 +525,17 @@ func (c *Competition) AssignAdvancedRoundCompetitors() {
 			competitors = append(competitors, competitorMap[r.PersonId])
 		}
 
+		groups := c.roundGroups(r.ActivityCode)
+		if len(groups) == 0 {
+			continue
+		}
+
 		competitorCount := len(competitors)
-		baseGroupSize := competitorCount / r.GroupCount
-		extras := competitorCount % r.GroupCount
+		baseGroupSize := competitorCount / len(groups)
+		extras := competitorCount % len(groups)
 
 		start := 0
-		for j := range r.GroupCount {
+		for j, g := range groups {
 			groupSize := baseGroupSize
 			if j < extras {
 				groupSize++
@@ -265,18 +547,57 @@ func (c *Competition) AssignAdvancedRoundCompetitors() {
 			sort.Slice(groupCompetitors, func(i, j int) bool {
 				return groupCompetitors[i].Name < groupCompetitors[j].Name
 			})
-			c.Rounds[i].Groups[j].Competitors = groupCompetitors
+			g.Competitors = groupCompetitors
 			start = end
 		}
 	}
 	c.AssignStaff()
+	return nil
 }
 
+// assignPublishedGroups fills the groups of the round from the competitor
+// assignments in the WCIF. Returns false if none are published.
+func (c *Competition) assignPublishedGroups(r *Round) bool {
+	groups := make(map[int][]Person)
+	for _, p := range c.Persons {
+		for _, assign := range p.Assignments {
+			if assign.AssignmentCode == AssignmentCompetitor && assign.ActivityId != 0 {
+				groups[assign.ActivityId] = append(groups[assign.ActivityId], p.simplified())
+			}
+		}
+	}
+
+	published := false
+	for _, g := range r.Groups {
+		if len(groups[g.ActivityId]) > 0 {
+			published = true
+		}
+	}
+	if !published {
+		return false
+	}
+
+	for j, g := range r.Groups {
+		competitors := groups[g.ActivityId]
+		sort.Slice(competitors, func(i, j int) bool {
+			return competitors[i].Name < competitors[j].Name
+		})
+		r.Groups[j].Competitors = competitors
+	}
+	return true
+}
+
+// AssignStaff fills the staff of every group. Rounds with staff assignments in
+// the WCIF use those, otherwise the competitors of the previous group staff the next.
 func (c *Competition) AssignStaff() {
 	for i, r := range c.Rounds {
 		// Empty the groups before populating them
 		for j := range r.Groups {
-			c.Rounds[i].Groups[j].Staff = []Person{}
+			c.Rounds[i].Groups[j].clearStaff()
+		}
+
+		if c.assignPublishedStaff(&c.Rounds[i]) {
+			continue
 		}
 
 		totalGroups := len(r.Groups)
@@ -291,143 +612,115 @@ func (c *Competition) AssignStaff() {
 	}
 }
 
-func (c *Competition) loadAdvancedRoundData() {
-	scrambleDir := fmt.Sprintf("./%s - Computer Display PDFs", c.Name)
-	scrambleSets, err := os.ReadDir(scrambleDir)
-	if err != nil {
-		log.Fatalf("Could not read scramble sets: %v", err)
-	}
-
+func (c *Competition) loadAdvancedRoundData(scrambles *tnoodle.Index) {
+	seen := make(map[string]bool)
 	for i, r := range c.Rounds {
 		// Skip initial rounds
 		if r.RoundNumber == 1 {
 			continue
 		}
 
-		var roundScrambleSets []string
-		for _, s := range scrambleSets {
-			eventName := strings.ReplaceAll(r.EventName, ",", "")
-			eventName = strings.ReplaceAll(eventName, " Cube", "")
-			if strings.HasPrefix(s.Name(), eventName) {
-				roundScrambleSets = append(roundScrambleSets, s.Name())
-			}
-		}
-
-		// Assign group count to the round
-		c.Rounds[i].GroupCount = len(roundScrambleSets)
-
-		for j, s := range roundScrambleSets {
-
-			if j < len(r.Groups) {
-				continue
-			}
-
-			roundName := c.Rounds[i].EventName
-			roundNumber := int(roundName[len(roundName)-1]) - 48
-			groupNumber := j + 1
-			eventName := strings.Split(s, " Scramble")[0]
-			activityCode := fmt.Sprintf("%s-g%d", r.ActivityCode, j+1)
-
-			group := Group{
-				EventName:    eventName,
-				RoundNumber:  roundNumber,
-				GroupNumber:  groupNumber,
-				Opened:       false,
-				Finished:     false,
-				ActivityCode: activityCode,
-				// TODO Calculate this based of how many groups the round has
-				StartTime: r.StartTime,
-				EndTime:   r.EndTime,
+		// Rounds split across rooms share their scramble sets, only add groups once
+		if !seen[r.ActivityCode] {
+			seen[r.ActivityCode] = true
+			for _, set := range scrambles.Sets(r.EventId, r.RoundNumber) {
+				if _, g := c.findGroup(fmt.Sprintf("%s-g%d", r.ActivityCode, set)); g != nil {
+					continue
+				}
+				c.Rounds[i].Groups = append(c.Rounds[i].Groups, c.Rounds[i].newGroup(set))
 			}
-			c.Rounds[i].Groups = append(c.Rounds[i].Groups, group)
 		}
+		// Only count the groups in this room
+		c.Rounds[i].GroupCount = len(c.Rounds[i].Groups)
 	}
 }
 
-func (c *Competition) loadInitialRoundData() {
-	scrambleDir := fmt.Sprintf("./%s - Computer Display PDFs/", c.Name)
-	scrambleSets, err := os.ReadDir(scrambleDir)
-	if err != nil {
-		log.Fatalf("Could not read scramble sets: %v", err)
-	}
-
+func (c *Competition) loadInitialRoundData(scrambles *tnoodle.Index) {
 	for i, r := range c.Rounds {
 		// Skip advanced rounds
 		if r.RoundNumber != 1 {
 			continue
 		}
 
-		var roundScrambleSets []string
-		for _, s := range scrambleSets {
-			eventName := strings.ReplaceAll(r.EventName, ",", "")
-			eventName = strings.ReplaceAll(eventName, " Cube", "")
-			if strings.HasPrefix(s.Name(), eventName) {
-				roundScrambleSets = append(roundScrambleSets, s.Name())
-			}
+		// Rounds split across rooms share their scramble sets, only add groups once
+		if c.roundGroupCount(r.ActivityCode) > 0 {
+			continue
 		}
 
-		// Assign group count to the round
-		c.Rounds[i].GroupCount = len(roundScrambleSets)
+		sets := scrambles.Sets(r.EventId, r.RoundNumber)
+		c.Rounds[i].GroupCount = len(sets)
 
-		for j, s := range roundScrambleSets {
-
-			roundNumber := i + 1
-			groupNumber := j + 1
-			eventName := strings.Split(s, " Scramble")[0]
-			activityCode := fmt.Sprintf("%s-g%d", r.ActivityCode, j+1)
-
-			group := Group{
-				EventName:    eventName,
-				RoundNumber:  roundNumber,
-				GroupNumber:  groupNumber,
-				Opened:       false,
-				Finished:     false,
-				ActivityCode: activityCode,
-				// TODO Calculate this based of how many groups the round has
-				StartTime: r.StartTime,
-				EndTime:   r.EndTime,
-			}
-			c.Rounds[i].Groups = append(c.Rounds[i].Groups, group)
+		for _, set := range sets {
+			c.Rounds[i].Groups = append(c.Rounds[i].Groups, c.Rounds[i].newGroup(set))
 		}
 	}
 }
 
-func (c *Competition) groupsLoaded() bool {
+// newGroup creates the group using the given scramble set of the round
+func (r *Round) newGroup(set int) Group {
+	return Group{
+		EventName:    tnoodle.RoundName(r.EventId, r.RoundNumber),
+		EventId:      r.EventId,
+		RoundNumber:  r.RoundNumber,
+		GroupNumber:  set,
+		Lifecycle:    Lifecycle{State: StatePending},
+		ActivityCode: fmt.Sprintf("%s-g%d", r.ActivityCode, set),
+		// Split across the groups by splitGroupTimes once they are all added
+		StartTime: r.StartTime,
+		EndTime:   r.EndTime,
+	}
+}
+
+// checkScrambleSets reports the groups without exactly one scramble set file,
+// and the rounds without any
+func (c *Competition) checkScrambleSets(scrambles *tnoodle.Index) []error {
+	problems := slices.Clone(scrambles.Problems)
 	for _, r := range c.Rounds {
-		// Ignore advanced rounds
-		if r.RoundNumber != 1 {
-			continue
+		if len(r.Groups) == 0 && c.roundGroupCount(r.ActivityCode) == 0 {
+			problems = append(problems, fmt.Errorf("%w: no sets found for %s", tnoodle.ErrMissing, tnoodle.RoundName(r.EventId, r.RoundNumber)))
 		}
+		for _, g := range r.Groups {
+			keys := []tnoodle.Key{g.scrambleKey()}
+			if len(g.Attempts) > 0 {
+				keys = nil
+				for _, a := range g.Attempts {
+					keys = append(keys, a.scrambleKey(&g))
+				}
+			}
 
-		return len(r.Groups) > 0
+			for _, key := range keys {
+				// Ambiguous sets are already among the problems found by the scan
+				_, err := scrambles.Lookup(key)
+				if err != nil && !errors.Is(err, tnoodle.ErrAmbiguous) {
+					problems = append(problems, err)
+				}
+			}
+		}
 	}
-	return false
+	return problems
 }
 
-func (c *Competition) LoadRoundData() {
+func (c *Competition) LoadRoundData() error {
 	for i, r := range c.Rounds {
-		roundNumber := int(r.EventName[len(r.EventName)-1]) - 48
+		roundNumber, _ := parseRoundNumber(r.ActivityCode)
 		c.Rounds[i].RoundNumber = roundNumber
 		c.Rounds[i].GroupCount = len(r.Groups)
 		c.Rounds[i].SortGroups()
-		for j := range r.Groups {
-			activityCode := fmt.Sprintf("%s-g%d", r.ActivityCode, j+1)
+		for j, g := range r.Groups {
+			// Groups of a round split across rooms keep the number given in the schedule
+			groupNumber, ok := parseGroupNumber(g.ActivityCode)
+			if !ok {
+				groupNumber = j + 1
+			}
+			activityCode := fmt.Sprintf("%s-g%d", r.ActivityCode, groupNumber)
 			c.Rounds[i].Groups[j].RoundNumber = roundNumber
 			c.Rounds[i].Groups[j].EventId = r.EventId
 			c.Rounds[i].Groups[j].ActivityCode = activityCode
-			c.Rounds[i].Groups[j].GroupNumber = j + 1
+			c.Rounds[i].Groups[j].GroupNumber = groupNumber
 		}
 	}
 
-	if !c.groupsLoaded() {
-		fmt.Println("No initial round groups")
-		c.loadInitialRoundData()
-	}
-
-	c.loadAdvancedRoundData()
-	c.AssignCompetitors()
-	c.AssignStaff()
-	c.loadPasswords()
+	return c.loadScrambles(c.scrambleFiles())
 }
 
 func (c *Competition) LoadAvatars() error {
@@ -491,232 +784,360 @@ func (c *Competition) SortRounds() {
 
 func (r *Round) SortGroups() {
 	sort.Slice(r.Groups, func(i, j int) bool {
+		a, _ := parseGroupNumber(r.Groups[i].ActivityCode)
+		b, _ := parseGroupNumber(r.Groups[j].ActivityCode)
+		if a != b {
+			return a < b
+		}
 		return r.Groups[i].ActivityCode < r.Groups[j].ActivityCode
 	})
 }
 
-func (c *Competition) nextRound() *Round {
-	for i, r := range c.Rounds {
-		if !r.Finished {
-			return &c.Rounds[i]
-		}
-	}
-	return nil
+// parseGroupNumber reads the group number from an activity code like 333-r1-g2
+func parseGroupNumber(activityCode string) (int, bool) {
+	return activityCodePart(activityCode, "g")
 }
 
-func (r *Round) nextGroup() *Group {
-	for i, g := range r.Groups {
-		if !g.Finished {
-			return &r.Groups[i]
+// parseRoundNumber reads the round number from an activity code like 333-r1-g2
+func parseRoundNumber(activityCode string) (int, bool) {
+	return activityCodePart(activityCode, "r")
+}
+
+// parseAttemptNumber reads the attempt number from an activity code like 333fm-r1-a2
+func parseAttemptNumber(activityCode string) (int, bool) {
+	return activityCodePart(activityCode, "a")
+}
+
+func activityCodePart(activityCode, prefix string) (int, bool) {
+	// The first part is the event ID
+	parts := strings.Split(activityCode, "-")
+	for _, part := range parts[1:] {
+		if !strings.HasPrefix(part, prefix) {
+			continue
+		}
+		n, err := strconv.Atoi(part[len(prefix):])
+		if err == nil {
+			return n, true
 		}
 	}
-	return nil
+	return 0, false
 }
 
-func (c *Competition) StartNextGroup() {
-	round := c.nextRound()
-	if round == nil {
-		fmt.Println("No new rounds")
+// splitGroupTimes gives the groups that only know the time slot of the round an
+// equal share of it. Groups with their own times from the schedule keep them.
+func (r *Round) splitGroupTimes() {
+	// Groups doing attempts take the times of their attempts
+	if len(r.Groups) < 2 || r.PerAttempt() {
 		return
 	}
 
-	group := round.nextGroup()
+	slot := r.EndTime.Sub(r.StartTime) / time.Duration(len(r.Groups))
+	for j, g := range r.Groups {
+		if !g.StartTime.Equal(r.StartTime) || !g.EndTime.Equal(r.EndTime) {
+			continue
+		}
+		r.Groups[j].StartTime = r.StartTime.Add(slot * time.Duration(j))
+		r.Groups[j].EndTime = r.Groups[j].StartTime.Add(slot)
+		if j == len(r.Groups)-1 {
+			r.Groups[j].EndTime = r.EndTime
+		}
+	}
+}
+
+// StartNextGroup closes the groups on the floor and opens the group, or the
+// attempt of a group scrambled per attempt, scheduled next after the operator confirms it
+func (c *Competition) StartNextGroup(confirm Confirmer, maxEarly time.Duration) error {
+	group, attempt := c.Next()
 	if group == nil {
-		fmt.Println("No new rounds")
-		return
+		return ErrNoUpcomingGroup
 	}
 
-	msg := fmt.Sprintf("Are you sure you want to open %s", group.ScrambleSet())
-	yes := confirmationMessage(msg)
-	if !yes {
-		os.Exit(0)
+	if attempt != nil {
+		err := confirmOpen(confirm, attempt.ScrambleSet(group), attempt.StartTime, maxEarly)
+		if err != nil {
+			return err
+		}
+	} else {
+		err := confirmOpen(confirm, group.ScrambleSet(), group.StartTime, maxEarly)
+		if err != nil {
+			return err
+		}
+	}
+	err := canOpen(group, attempt)
+	if err != nil {
+		return err
 	}
 
-	if group.StartTime.After(time.Now().Add(15 * time.Minute)) {
-		timeToStart := group.StartTime.Sub(time.Now())
-		hours := int(timeToStart.Hours())
-		minutes := int(timeToStart.Minutes()) % 60
+	active := c.activeGroups()
+	snapshot := active
+	if !slices.Contains(active, group) {
+		snapshot = append(slices.Clone(active), group)
+	}
+	op := c.beginOperation(OpOpen, snapshot...)
 
-		msg := fmt.Sprintf("\nAre you sure?\nRound is not supposed to start in %dh %dm\n", hours, minutes)
-		yes := confirmationMessage(msg)
-		if !yes {
-			os.Exit(0)
+	// Ensure we have the competitors for the advanced rounds
+	if group.RoundNumber > 1 {
+		err := c.AssignAdvancedRoundCompetitors()
+		if err != nil {
+			return err
 		}
 	}
 
-	if round.GroupCount == group.GroupNumber {
-		round.Finished = true
+	err = group.DrawRoundPDF()
+	if err != nil {
+		return err
 	}
 
-	group.Finished = true
+	err = sendFile("profiles.pdf", config.CompetitorListURL)
+	if err != nil {
+		return fmt.Errorf("could not send groups: %w", err)
+	}
 
-	// Ensure we have the competitors for the advanced rounds
-	if group.RoundNumber > 1 {
-		c.AssignAdvancedRoundCompetitors()
+	// The watermark shows the time the scrambles are recorded as opened
+	now := time.Now()
+	scramble := group.ActivityCode
+	if attempt != nil {
+		scramble = attempt.ActivityCode
+		err = attempt.SendPDF(c, group, now)
+	} else {
+		err = group.SendPDF(c, now)
 	}
-	group.DrawRoundPDF()
-	err := curl("profiles.pdf", config.CompetitorListURL)
 	if err != nil {
-		log.Fatalf("Could not send groups: %v", err)
+		return fmt.Errorf("could not send PDF: %w", err)
 	}
 
-	err = group.SendPDF(c.Name)
+	var closed []string
+	for _, g := range active {
+		previous := g.activeAttempt()
+		if previous != nil {
+			closed = append(closed, previous.ActivityCode)
+		}
+		// The group opening its next attempt stays on the floor
+		if g == group {
+			continue
+		}
+
+		err = g.closeAttempt(now)
+		if err != nil {
+			return err
+		}
+		err = g.transition(g.ActivityCode, StateClosed, now)
+		if err != nil {
+			return err
+		}
+		if previous == nil {
+			closed = append(closed, g.ActivityCode)
+		}
+	}
+	err = openScrambles(group, attempt, now)
 	if err != nil {
-		log.Fatalf("Could not send PDF: %v\n", err)
+		return err
+	}
+	for _, code := range closed {
+		c.auditOnSave(audit.ActionClose, code, "")
 	}
+	c.auditOnSave(audit.ActionOpen, scramble, "")
+
+	c.Display = DisplayState{Scramble: scramble, Screen: ScreenRound, Group: group.ActivityCode}
+	c.recordOperation(op)
+	return nil
 }
 
-func curl(file, url string) error {
-	f := fmt.Sprintf("file=@%s", file)
-	cmd := exec.Command(
-		"curl",
-		"--cert", config.ClientCrt,
-		"--key", config.ClientKey,
-		"--cacert", config.CaCrt,
-		"-F", f,
-		url,
-	)
-	_, err := cmd.CombinedOutput()
+// confirmOpen asks the operator to confirm opening the scramble set. Opening
+// more than maxEarly before the planned start needs a second confirmation, and
+// is refused when nobody is there to give it.
+func confirmOpen(confirm Confirmer, scrambleSet string, start time.Time, maxEarly time.Duration) error {
+	early := start.After(time.Now().Add(maxEarly))
+	timeToStart := start.Sub(time.Now())
+	hours := int(timeToStart.Hours())
+	minutes := int(timeToStart.Minutes()) % 60
+	if _, ok := confirm.(unattended); ok && early {
+		return fmt.Errorf("%w: %s is not supposed to start in %dh %dm", ErrTooEarly, scrambleSet, hours, minutes)
+	}
+
+	msg := fmt.Sprintf("Are you sure you want to open %s", scrambleSet)
+	yes, err := confirm.Confirm(msg)
 	if err != nil {
 		return err
 	}
+	if !yes {
+		return ErrCancelled
+	}
+
+	if early {
+		msg := fmt.Sprintf("\nAre you sure?\nRound is not supposed to start in %dh %dm\n", hours, minutes)
+		yes, err := confirm.Confirm(msg)
+		if err != nil {
+			return err
+		}
+		if !yes {
+			return ErrCancelled
+		}
+	}
 	return nil
 }
 
+// Save writes the competition to disk and keeps a backup of the state it replaces
 func (c *Competition) Save() error {
-	file, err := os.Create(c.SaveLocation())
+	return c.save(true)
+}
+
+func (c *Competition) save(backup bool) error {
+	// Passwords never reach the disk in plaintext
+	err := c.sealPasswords()
 	if err != nil {
 		return err
 	}
-	defer file.Close()
 
-	encoder := json.NewEncoder(file)
-	encoder.SetIndent("", "  ")
-	return encoder.Encode(c)
+	if backup {
+		err = c.backupPrevious()
+		if err != nil {
+			return fmt.Errorf("could not back up competition: %w", err)
+		}
+	}
+	err = writeJSONAtomic(c.SaveLocation(), c)
+	if err != nil || len(c.pendingAudit) == 0 {
+		return err
+	}
+
+	// Keep the entries of the saved changes as the anchor of the audit log
+	c.flushAudit()
+	return writeJSONAtomic(c.SaveLocation(), c)
 }
 
 // Export the competition with person data removed, for future data analysis
 func (c *Competition) Export() error {
-	// Remove persons data from the export
+	// Remove persons data and scramble passwords from the export
 	c.Persons = nil
+	c.Sealing = nil
 	for i, r := range c.Rounds {
 		c.Rounds[i].Results = nil
-		for j := range r.Groups {
+		for j, g := range r.Groups {
+			c.Rounds[i].Groups[j].SealedPassword = ""
+			c.Rounds[i].Groups[j].Password = ""
+			for k := range g.Attempts {
+				c.Rounds[i].Groups[j].Attempts[k].SealedPassword = ""
+				c.Rounds[i].Groups[j].Attempts[k].Password = ""
+			}
 			c.Rounds[i].Groups[j].Competitors = nil
 			c.Rounds[i].Groups[j].Staff = nil
+			c.Rounds[i].Groups[j].Judges = nil
+			c.Rounds[i].Groups[j].Scramblers = nil
+			c.Rounds[i].Groups[j].Runners = nil
+			c.Rounds[i].Groups[j].DataEntry = nil
 		}
 	}
 	saveLocation := fmt.Sprintf("%s.json", c.ID)
 	exportPath := filepath.Join(config.AppDataDir, "archive", saveLocation)
-	file, err := os.Create(exportPath)
-	if err != nil {
-		return err
-	}
-	defer file.Close()
-
-	encoder := json.NewEncoder(file)
-	encoder.SetIndent("", "  ")
-	return encoder.Encode(c)
+	return writeJSONAtomic(exportPath, c)
 }
 
 func (c *Competition) SaveLocation() string {
 	return fmt.Sprintf("%s/competition.json", config.AppDataDir)
 }
 
-func (g *Group) event() string {
-	event := strings.ReplaceAll(g.EventName, ",", "")
-	event = strings.ReplaceAll(event, " Cube", "")
-	event = strings.Split(event, " Group")[0]
-	return event
+// ScrambleSet is the name TNoodle gave the scramble set of the group
+func (g Group) ScrambleSet() string {
+	return g.scrambleKey().String()
 }
 
-func (g Group) ScrambleSet() string {
-	groupLetter := string(rune('A' + g.GroupNumber - 1))
-	return fmt.Sprintf("%s Scramble Set %s", g.event(), groupLetter)
+func (g Group) scrambleKey() tnoodle.Key {
+	return tnoodle.Key{EventID: g.EventId, Round: g.RoundNumber, Set: g.GroupNumber}
 }
 
-func (g *Group) SendPDF(competition string) error {
-	scrambleDir := fmt.Sprintf("./%s - Computer Display PDFs/", competition)
-	err := pdf.DecryptPDF(fmt.Sprintf("%s/%s.pdf", scrambleDir, g.ScrambleSet()), g.Password)
+// SendPDF sends the scramble set of the group to the display, watermarked as opened at opened
+func (g *Group) SendPDF(c *Competition, opened time.Time) error {
+	password, err := c.open(g.SealedPassword)
 	if err != nil {
 		return err
 	}
-
-	// TODO: Define this on a program level
-	scrambleFile := path.Join(config.AppDataDir, "active.pdf")
-	err = curl(scrambleFile, config.ScrambleURL)
+	err = c.sendScrambles(g.ActivityCode, g.scrambleKey(), password, opened)
 	if err != nil {
-		fmt.Printf("Curl Status Code: %v\n", err)
+		return err
 	}
-
-	g.Opened = true
-	g.OpenedTimestamp = append(g.OpenedTimestamp, time.Now())
+	c.auditLog(audit.ActionSend, g.ActivityCode, fmt.Sprintf("%s, session %s", g.ScrambleSet(), sessionID))
 	return nil
 }
 
-func (c *Competition) loadPasswords() error {
-	passwordFile := fmt.Sprintf("%s - Computer Display PDF Passcodes - SECRET.txt", c.Name)
-	file, err := os.Open(passwordFile)
+// SendPDF sends the scramble set of the attempt to the display, watermarked as opened at opened
+func (a *Attempt) SendPDF(c *Competition, g *Group, opened time.Time) error {
+	password, err := c.open(a.SealedPassword)
 	if err != nil {
 		return err
 	}
-	defer file.Close()
-
-	passwords := make(map[string]string)
-	scanner := bufio.NewScanner(file)
+	err = c.sendScrambles(a.ActivityCode, a.scrambleKey(g), password, opened)
+	if err != nil {
+		return err
+	}
+	c.auditLog(audit.ActionSend, a.ActivityCode, fmt.Sprintf("%s, session %s", a.ScrambleSet(g), sessionID))
+	return nil
+}
 
-	for scanner.Scan() {
-		line := strings.TrimSpace(scanner.Text())
-		if line == "" {
-			continue
-		}
+func (c *Competition) sendScrambles(activity string, key tnoodle.Key, password string, opened time.Time) error {
+	scrambleFile, err := c.decryptScrambles(activity, key, password, opened)
+	if err != nil {
+		return err
+	}
 
-		parts := strings.SplitN(line, ":", 2)
-		if len(parts) != 2 {
-			continue
-		}
+	return sendFile(scrambleFile, config.ScrambleURL)
+}
 
-		key := strings.TrimSpace(parts[0])
-		value := strings.TrimSpace(parts[1])
-		passwords[key] = value
+// pushScrambles sends the scramble set to the display without marking anything
+// as opened. The watermark keeps the time the scrambles were opened at.
+func (c *Competition) pushScrambles(activity string, key tnoodle.Key, sealed string, opened time.Time) error {
+	password, err := c.open(sealed)
+	if err != nil {
+		return err
 	}
-
-	err = scanner.Err()
+	if opened.IsZero() {
+		opened = time.Now()
+	}
+	scrambleFile, err := c.decryptScrambles(activity, key, password, opened)
 	if err != nil {
 		return err
 	}
-
-	for i, r := range c.Rounds {
-		for j, g := range r.Groups {
-			password, ok := passwords[g.ScrambleSet()]
-			if ok {
-				c.Rounds[i].Groups[j].Password = password
-			} else {
-				log.Fatalf("Password not found for: %s", g.ScrambleSet())
-			}
-		}
+	err = sendFile(scrambleFile, config.ScrambleURL)
+	if err != nil {
+		return err
 	}
+	c.auditLog(audit.ActionSend, activity, fmt.Sprintf("%s, session %s", key, sessionID))
 	return nil
 }
 
-func confirmationMessage(prompt string) bool {
-	reader := bufio.NewReader(os.Stdin)
-	fmt.Printf("%s (y/N): ", prompt)
-	input, err := reader.ReadString('\n')
+// decryptScrambles decrypts the scramble set and watermarks it with the activity
+// code, the time it was opened and the session, unless watermarks are turned off
+func (c *Competition) decryptScrambles(activity string, key tnoodle.Key, password string, opened time.Time) (string, error) {
+	scrambles, err := tnoodle.ScanDir(c.ScrambleDir())
 	if err != nil {
-		fmt.Printf("Error reading input: %v\n", err)
-		return false
+		return "", err
+	}
+	set, err := scrambles.Lookup(key)
+	if err != nil {
+		return "", err
+	}
+
+	err = pdf.DecryptPDF(set.Path, password)
+	if err != nil {
+		return "", err
 	}
 
-	input = strings.TrimSpace(strings.ToLower(input))
-	return input == "y" || input == "yes"
+	// TODO: Define this on a program level
+	scrambleFile := path.Join(config.AppDataDir, "active.pdf")
+	if c.Watermark.Disabled {
+		return scrambleFile, nil
+	}
+	err = pdf.Watermark(scrambleFile, watermarkText(activity, opened), c.Watermark.style())
+	if err != nil {
+		return "", err
+	}
+	return scrambleFile, nil
 }
 
 // TODO Change this (DRY)
-func (g *Group) DrawHandInPDF() {
+func (g *Group) DrawHandInPDF() error {
 	// TODO: Copy placeholder PDF
 	if len(g.Competitors) == 0 {
-		fmt.Println("Draw hand-in PDF: No competitors found.")
+		logf("Draw hand-in PDF: No competitors found.")
 	}
 	pdf := gofpdf.NewCustom(&gofpdf.InitType{
 		UnitStr: "pt",
@@ -735,9 +1156,10 @@ func (g *Group) DrawHandInPDF() {
 	xRight := xLeft + colWidth + pageMargin
 
 	yStart := pageMargin + sectionTitleHeight + 20
+	staff := g.StaffMembers()
 	competitorCount := len(g.Competitors) * 2 // Multiply by two for some reason
-	if len(g.Staff) > competitorCount/2 {
-		competitorCount = len(g.Staff) * 2
+	if len(staff) > competitorCount/2 {
+		competitorCount = len(staff) * 2
 	}
 	rows := math.Ceil(float64(competitorCount) / 2.0)
 
@@ -776,7 +1198,7 @@ func (g *Group) DrawHandInPDF() {
 	// Define title of staff column based of whether we have assigned staff or not
 	var staffTitle string
 
-	if len(g.Staff) > 0 {
+	if len(staff) > 0 {
 		staffTitle = "Staff"
 	} else {
 		staffTitle = "No staff assigned"
@@ -811,15 +1233,15 @@ func (g *Group) DrawHandInPDF() {
 		}
 
 		if i < len(g.Competitors) {
-			drawPerson(pdf, g.Competitors[i], xLeft, y, imgSize, colWidth)
+			drawPerson(pdf, g.Competitors[i], "", xLeft, y, imgSize, colWidth)
 			i++
 		}
-		if j < len(g.Staff) {
-			drawPerson(pdf, g.Staff[j], xRight, y, imgSize, colWidth)
+		if j < len(staff) {
+			drawPerson(pdf, staff[j].Person, staff[j].Role, xRight, y, imgSize, colWidth)
 			j++
 		}
 
-		if i >= len(g.Competitors) && j >= len(g.Staff) {
+		if i >= len(g.Competitors) && j >= len(staff) {
 			break
 		}
 		rowCount++
@@ -827,16 +1249,17 @@ func (g *Group) DrawHandInPDF() {
 
 	err := pdf.OutputFileAndClose("profiles.pdf")
 	if err != nil {
-		log.Fatalf("Could not draw hand-in pdf: %v", err)
+		return fmt.Errorf("could not draw hand-in PDF: %w", err)
 	}
-	fmt.Printf("Hand-in opened for %s\n", g.EventName)
+	logf("Hand-in opened for %s", g.EventName)
+	return nil
 }
 
 // TODO Change this (DRY)
-func (g *Group) DrawRoundPDF() {
+func (g *Group) DrawRoundPDF() error {
 	// TODO: Copy placeholder PDF
 	if len(g.Competitors) == 0 {
-		fmt.Println("Draw hand-in PDF: No competitors found.")
+		logf("Draw hand-in PDF: No competitors found.")
 	}
 	pdf := gofpdf.NewCustom(&gofpdf.InitType{
 		UnitStr: "pt",
@@ -853,9 +1276,10 @@ func (g *Group) DrawRoundPDF() {
 	xLeft := pageMargin
 	xRight := xLeft + colWidth + pageMargin
 
+	staff := g.StaffMembers()
 	competitorCount := len(g.Competitors) * 2 // Multiply by two for some reason
-	if len(g.Staff) > competitorCount/2 {
-		competitorCount = len(g.Staff) * 2
+	if len(staff) > competitorCount/2 {
+		competitorCount = len(staff) * 2
 	}
 	yStart := pageMargin + sectionTitleHeight + 20
 	rows := math.Ceil(float64(competitorCount) / 2.0)
@@ -875,7 +1299,7 @@ func (g *Group) DrawRoundPDF() {
 	pdf.SetFillColor(44, 62, 80)
 	pdf.Rect(0, 0, 1920, 1080, "F")
 
-    pdf.SetFontLocation(config.FontDir)
+	pdf.SetFontLocation(config.FontDir)
 	pdf.AddUTF8Font("hack", "", "HackNerdFont-Regular.ttf")
 	pdf.AddUTF8Font("hack", "B", "HackNerdFont-Bold.ttf")
 	font := "hack"
@@ -896,7 +1320,7 @@ func (g *Group) DrawRoundPDF() {
 	// Define title of staff column based of whether we have assigned staff or not
 	var staffTitle string
 
-	if len(g.Staff) > 0 {
+	if len(staff) > 0 {
 		staffTitle = "Staff"
 	} else {
 		staffTitle = "No staff assigned"
@@ -941,15 +1365,15 @@ func (g *Group) DrawRoundPDF() {
 		// 	return staff[i].Name > staff[j].Name
 		// })
 		if i < len(g.Competitors) {
-			drawPerson(pdf, g.Competitors[i], xLeft, y, imgSize, colWidth)
+			drawPerson(pdf, g.Competitors[i], "", xLeft, y, imgSize, colWidth)
 			i++
 		}
-		if j < len(g.Staff) {
-			drawPerson(pdf, g.Staff[j], xRight, y, imgSize, colWidth)
+		if j < len(staff) {
+			drawPerson(pdf, staff[j].Person, staff[j].Role, xRight, y, imgSize, colWidth)
 			j++
 		}
 
-		if i >= len(g.Competitors) && j >= len(g.Staff) {
+		if i >= len(g.Competitors) && j >= len(staff) {
 			break
 		}
 		rowCount++
@@ -957,8 +1381,9 @@ func (g *Group) DrawRoundPDF() {
 
 	err := pdf.OutputFileAndClose("profiles.pdf")
 	if err != nil {
-		log.Fatalf("Could not output and close profiles.pdf: %v", err)
+		return fmt.Errorf("could not output and close profiles.pdf: %w", err)
 	}
+	return nil
 }
 
 func removeJPGFiles(dirPath string) error {
@@ -977,7 +1402,7 @@ func removeJPGFiles(dirPath string) error {
 			fullPath := filepath.Join(dirPath, name)
 			err := os.Remove(fullPath)
 			if err != nil {
-				fmt.Printf("Failed to delete %s: %v\n", fullPath, err)
+				logf("Failed to delete %s: %v", fullPath, err)
 			}
 		}
 	}
@@ -1002,8 +1427,8 @@ func ConvertFilesToJPGs(dirPath string) error {
 		outputPath := filepath.Join(dirPath, fileName+".jpg")
 
 		cmd := exec.Command("ffmpeg", "-loglevel", "quiet", "-i", inputPath, "-q:v", "2", outputPath)
-		cmd.Stdout = os.Stdout
-		cmd.Stderr = os.Stderr
+		cmd.Stdout = Log
+		cmd.Stderr = Log
 
 		cmd.Run()
 	}
@@ -1011,7 +1436,7 @@ func ConvertFilesToJPGs(dirPath string) error {
 	return nil
 }
 
-func drawPerson(pdf *gofpdf.Fpdf, person Person, x, y, imgSize, colWidth float64) {
+func drawPerson(pdf *gofpdf.Fpdf, person Person, role string, x, y, imgSize, colWidth float64) {
 	imgPath := filepath.Clean(fmt.Sprintf("%s.jpg", person.ImagePath()))
 	fallbackPath := fmt.Sprintf("%s/placeholder.jpg", config.AppDataDir)
 
@@ -1030,7 +1455,11 @@ func drawPerson(pdf *gofpdf.Fpdf, person Person, x, y, imgSize, colWidth float64
 	textX := x + imgSize + 10
 	textY := y + imgSize/2 - 8
 	pdf.SetXY(textX, textY)
-	pdf.CellFormat(colWidth-imgSize-10, 16, person.Name, "", 0, "L", false, 0, "")
+	name := person.Name
+	if role != "" {
+		name = fmt.Sprintf("%s (%s)", person.Name, role)
+	}
+	pdf.CellFormat(colWidth-imgSize-10, 16, name, "", 0, "L", false, 0, "")
 }
 
 type wcifSchedule struct {
@@ -1039,10 +1468,12 @@ type wcifSchedule struct {
 
 type wcifVenue struct {
 	ID    int        `json:"id"`
+	Name  string     `json:"name"`
 	Rooms []wcifRoom `json:"rooms"`
 }
 type wcifRoom struct {
 	ID         int            `json:"id"`
+	Name       string         `json:"name"`
 	Activities []wcifActivity `json:"activities"`
 }
 
@@ -1062,10 +1493,15 @@ type wcifEvent struct {
 }
 
 type wcifRound struct {
-	ID      string
-	Results []Result
+	ID                   string
+	Format               string
+	AdvancementCondition *AdvancementCondition
+	ScrambleSetCount     int
+	Results              []Result
 }
 
+// ReloadPersons fetches the persons from the WCIF and assigns the groups again.
+// Groups computed from the results of the previous round keep their competitors.
 func (c *Competition) ReloadPersons() error {
 	var raw struct {
 		Persons []Person `json:"persons"`
@@ -1073,7 +1509,7 @@ func (c *Competition) ReloadPersons() error {
 
 	data, err := FetchWCIF(c.ID)
 	if err != nil {
-		log.Fatal(err)
+		return err
 	}
 
 	err = json.Unmarshal(data, &raw)
@@ -1082,6 +1518,16 @@ func (c *Competition) ReloadPersons() error {
 	}
 	c.Persons = raw.Persons
 
+	for i, r := range c.Rounds {
+		if r.GroupSource == GroupSourceComputed {
+			continue
+		}
+		for j := range r.Groups {
+			c.Rounds[i].Groups[j].Competitors = nil
+		}
+	}
+	c.AssignCompetitors()
+	c.AssignStaff()
 	return nil
 }
 
@@ -1091,7 +1537,8 @@ func BuildCompetitionFromWCIF(data []byte) (*Competition, error) {
 		Name     string        `json:"name"`
 		Schedule *wcifSchedule `json:"schedule"`
 		// Groups   []Group  `json:"childActivities"`
-		Persons []Person `json:"persons"`
+		Persons []Person    `json:"persons"`
+		Events  []wcifEvent `json:"events"`
 	}
 
 	err := json.Unmarshal(data, &raw)
@@ -1114,31 +1561,45 @@ func BuildCompetitionFromWCIF(data []byte) (*Competition, error) {
 
 	if raw.Schedule != nil {
 		for _, venue := range raw.Schedule.Venues {
-			if venue.ID == 1 {
-				for _, room := range venue.Rooms {
-					if room.ID == 1 {
-						for _, act := range room.Activities {
-							if strings.Contains(act.ActivityCode, "other") {
-								continue
-							}
-							comp.Rounds = append(comp.Rounds, Round{
-								ID:           act.ID,
-								EventName:    act.Name,
-								EventId:      strings.Split(act.ActivityCode, "-")[0],
-								Finished:     false,
-								ActivityCode: act.ActivityCode,
-								Groups:       act.Groups,
-								StartTime:    act.StartTime,
-								EndTime:      act.EndTime,
-							})
-						}
+			for _, room := range venue.Rooms {
+				comp.Rooms = append(comp.Rooms, Room{
+					ID:        room.ID,
+					Name:      room.Name,
+					VenueID:   venue.ID,
+					VenueName: venue.Name,
+				})
+
+				for _, act := range room.Activities {
+					if strings.Contains(act.ActivityCode, "other") {
+						continue
+					}
+					if _, ok := parseAttemptNumber(act.ActivityCode); ok {
+						comp.addAttemptActivity(act, venue.ID, room.ID)
+						continue
 					}
+					comp.Rounds = append(comp.Rounds, Round{
+						ID:           act.ID,
+						EventName:    act.Name,
+						EventId:      strings.Split(act.ActivityCode, "-")[0],
+						VenueID:      venue.ID,
+						RoomID:       room.ID,
+						ActivityCode: act.ActivityCode,
+						Groups:       act.Groups,
+						StartTime:    act.StartTime,
+						EndTime:      act.EndTime,
+					})
 				}
 			}
 		}
 	}
 
-	comp.LoadRoundData()
+	// Every room is managed until the desk is told otherwise with SelectRooms
+	comp.applyEvents(raw.Events)
+	err = comp.LoadRoundData()
+	if err != nil {
+		return nil, err
+	}
+
 	comp.SortRounds()
 	return comp, nil
 }
@@ -1158,11 +1619,21 @@ func LoadCompetitionFromFile(filename string) (*Competition, error) {
 		return nil, err
 	}
 
+	comp.migrate()
+	// Fill in what competitions saved by older versions are missing
+	for i, r := range comp.Rounds {
+		for j, g := range r.Groups {
+			if g.EventId == "" {
+				comp.Rounds[i].Groups[j].EventId = r.EventId
+			}
+		}
+		comp.Rounds[i].splitGroupTimes()
+	}
 	return &comp, nil
 }
 
 func FetchWCIF(competitionID string) ([]byte, error) {
-	url := fmt.Sprintf("https://www.worldcubeassociation.org/api/v0/competitions/%s/wcif/public", competitionID)
+	url := fmt.Sprintf("%s/api/v0/competitions/%s/wcif/public", config.APIBaseURL, competitionID)
 
 	resp, err := http.Get(url)
 	if err != nil {
@@ -0,0 +1,208 @@
+package models
+
+import (
+	"fmt"
+	"path/filepath"
+	"slices"
+	"time"
+
+	"github.com/larsjohanfolde/scrambleman/scrambledesk/config"
+	"github.com/larsjohanfolde/scrambleman/scrambledesk/internal/audit"
+	"github.com/larsjohanfolde/scrambleman/scrambledesk/internal/upload"
+)
+
+// maxOperations is the number of transitions kept for undo
+const maxOperations = 50
+
+// sendFile uploads a file to the display, tests replace it to run without one
+var sendFile = upload.Send
+
+type OperationKind string
+
+const (
+	OpOpen   OperationKind = "open"
+	OpHandIn OperationKind = "hand-in"
+)
+
+const (
+	ScreenRound  = "round"
+	ScreenHandIn = "hand-in"
+)
+
+// DisplayState describes what the display is showing. An empty Scramble means
+// the intermission screen, Group is the group shown on the Screen.
+type DisplayState struct {
+	Scramble string
+	Screen   string
+	Group    string
+}
+
+// Operation is a state transition, holding everything needed to revert it
+type Operation struct {
+	Kind    OperationKind
+	Time    time.Time
+	Groups  []GroupSnapshot
+	Display DisplayState
+}
+
+type GroupSnapshot struct {
+	ActivityCode string
+	State        State
+	Transitions  []Transition
+	Attempts     []Lifecycle
+}
+
+// beginOperation snapshots the groups before a transition modifies them
+func (c *Competition) beginOperation(kind OperationKind, groups ...*Group) Operation {
+	op := Operation{
+		Kind:    kind,
+		Time:    time.Now(),
+		Display: c.Display,
+	}
+	for _, g := range groups {
+		snapshot := GroupSnapshot{
+			ActivityCode: g.ActivityCode,
+			State:        g.State,
+			Transitions:  slices.Clone(g.Transitions),
+		}
+		for _, a := range g.Attempts {
+			snapshot.Attempts = append(snapshot.Attempts, Lifecycle{State: a.State, Transitions: slices.Clone(a.Transitions)})
+		}
+		op.Groups = append(op.Groups, snapshot)
+	}
+	return op
+}
+
+// recordOperation adds a completed transition to the log
+func (c *Competition) recordOperation(op Operation) {
+	c.Operations = append(c.Operations, op)
+	if len(c.Operations) > maxOperations {
+		c.Operations = c.Operations[len(c.Operations)-maxOperations:]
+	}
+}
+
+// auditEntry is a change of state waiting to be recorded in the audit log
+type auditEntry struct {
+	action, activity, detail string
+}
+
+// auditLog records access to the scrambles in the audit log and keeps the
+// entry as the anchor saved with the competition. A failure is reported but
+// does not stop the competition.
+func (c *Competition) auditLog(action, activity, detail string) {
+	anchor, err := audit.Record(config.AuditFile, action, c.ID, activity, detail)
+	if err != nil {
+		logf("Could not write audit log: %v", err)
+		return
+	}
+	c.AuditAnchor = anchor
+}
+
+// auditOnSave records a change of state in the audit log once Save has
+// written it, so the log never shows a state the competition was not saved in.
+// Scramble sets sent to the display are recorded right away with auditLog.
+func (c *Competition) auditOnSave(action, activity, detail string) {
+	c.pendingAudit = append(c.pendingAudit, auditEntry{action: action, activity: activity, detail: detail})
+}
+
+// flushAudit records the changes of state waiting for the competition to be saved
+func (c *Competition) flushAudit() {
+	for _, e := range c.pendingAudit {
+		c.auditLog(e.action, e.activity, e.detail)
+	}
+	c.pendingAudit = nil
+}
+
+// Undo reverts the last transition and shows what the display showed before it.
+// Nothing is reverted unless the display could be restored.
+func (c *Competition) Undo() (*Operation, error) {
+	if len(c.Operations) == 0 {
+		return nil, ErrNothingToUndo
+	}
+	op := c.Operations[len(c.Operations)-1]
+
+	groups := make([]*Group, len(op.Groups))
+	for i, s := range op.Groups {
+		_, groups[i] = c.findGroup(s.ActivityCode)
+		if groups[i] == nil {
+			return nil, fmt.Errorf("group %s from the operation log not found", s.ActivityCode)
+		}
+	}
+
+	err := c.pushDisplay(op.Display)
+	if err != nil {
+		return nil, fmt.Errorf("could not restore the display, nothing was reverted: %w", err)
+	}
+
+	for i, s := range op.Groups {
+		g := groups[i]
+		g.State = s.State
+		g.Transitions = s.Transitions
+		for j := range s.Attempts {
+			if j < len(g.Attempts) {
+				g.Attempts[j].Lifecycle = s.Attempts[j]
+			}
+		}
+	}
+
+	c.Display = op.Display
+	c.Operations = c.Operations[:len(c.Operations)-1]
+	c.auditOnSave(audit.ActionUndo, "", string(op.Kind))
+	return &op, nil
+}
+
+// pushDisplay sends the screens described by the display state, without changing any group
+func (c *Competition) pushDisplay(d DisplayState) error {
+	if d.Scramble == "" {
+		err := sendFile(intermissionPDF(), config.ScrambleURL)
+		if err != nil {
+			return err
+		}
+	} else {
+		var err error
+		if _, g := c.findGroup(d.Scramble); g != nil {
+			err = c.pushScrambles(g.ActivityCode, g.scrambleKey(), g.SealedPassword, g.OpenedAt())
+		} else if g, a := c.findAttempt(d.Scramble); a != nil {
+			err = c.pushScrambles(a.ActivityCode, a.scrambleKey(g), a.SealedPassword, a.OpenedAt())
+		} else {
+			err = fmt.Errorf("%w: %s", ErrScrambleSetNotFound, d.Scramble)
+		}
+		if err != nil {
+			return err
+		}
+	}
+
+	if d.Group == "" {
+		return nil
+	}
+
+	_, g := c.findGroup(d.Group)
+	if g == nil {
+		return fmt.Errorf("group not found: %s", d.Group)
+	}
+	var err error
+	if d.Screen == ScreenHandIn {
+		err = g.DrawHandInPDF()
+	} else {
+		err = g.DrawRoundPDF()
+	}
+	if err != nil {
+		return err
+	}
+	return sendFile("profiles.pdf", config.CompetitorListURL)
+}
+
+func (c *Competition) findGroup(activityCode string) (*Round, *Group) {
+	for i, r := range c.Rounds {
+		for j, g := range r.Groups {
+			if g.ActivityCode == activityCode {
+				return &c.Rounds[i], &c.Rounds[i].Groups[j]
+			}
+		}
+	}
+	return nil, nil
+}
+
+func intermissionPDF() string {
+	return filepath.Join(config.AppDataDir, "templates", "intermission.pdf")
+}
@@ -0,0 +1,197 @@
+package models
+
+import (
+	"errors"
+	"fmt"
+	"slices"
+	"testing"
+	"time"
+)
+
+// fakeDisplay replaces the display, failing every upload with err. Returns the files sent.
+func fakeDisplay(t *testing.T, err error) *[]string {
+	t.Helper()
+	var sent []string
+	send := sendFile
+	sendFile = func(file, url string) error {
+		sent = append(sent, file)
+		return err
+	}
+	t.Cleanup(func() { sendFile = send })
+	return &sent
+}
+
+// undoCompetition has a round with two groups and an FMC round with two attempts
+func undoCompetition() *Competition {
+	r := Round{EventId: "333", RoundNumber: 1, ActivityCode: "333-r1"}
+	r.Groups = []Group{r.newGroup(1), r.newGroup(2)}
+
+	fm := Round{EventId: "333fm", RoundNumber: 1, ActivityCode: "333fm-r1"}
+	g := fm.newGroup(1)
+	for n := 1; n <= 2; n++ {
+		g.Attempts = append(g.Attempts, Attempt{Number: n, ActivityCode: fmt.Sprintf("%s-a%d", g.ActivityCode, n), Lifecycle: Lifecycle{State: StatePending}})
+	}
+	fm.Groups = []Group{g}
+	return &Competition{Rounds: []Round{r, fm}}
+}
+
+// move records an operation moving the groups to the given state and shows
+// the first group on the display
+func move(t *testing.T, c *Competition, kind OperationKind, to State, codes ...string) {
+	t.Helper()
+	var groups []*Group
+	for _, code := range codes {
+		_, g := c.findGroup(code)
+		groups = append(groups, g)
+	}
+	op := c.beginOperation(kind, groups...)
+	for _, g := range groups {
+		err := g.transition(g.ActivityCode, to, time.Now())
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+	c.Display = DisplayState{Scramble: codes[0], Screen: ScreenRound, Group: codes[0]}
+	c.recordOperation(op)
+}
+
+func TestUndo(t *testing.T) {
+	tests := []struct {
+		name       string
+		setup      func(t *testing.T, c *Competition)
+		displayErr error
+		wantErr    bool
+		// wantIs is the error Undo must return, if any
+		wantIs error
+		// want is the state of every group after undoing, by activity code
+		want map[string]State
+		// wantOps is the number of operations left in the log
+		wantOps int
+	}{
+		{
+			name:    "nothing to undo",
+			setup:   func(t *testing.T, c *Competition) {},
+			wantErr: true,
+			wantIs:  ErrNothingToUndo,
+			want:    map[string]State{"333-r1-g1": StatePending},
+		},
+		{
+			name: "open",
+			setup: func(t *testing.T, c *Competition) {
+				move(t, c, OpOpen, StateScramblesOpen, "333-r1-g1")
+			},
+			want: map[string]State{"333-r1-g1": StatePending},
+		},
+		{
+			name: "only the last operation",
+			setup: func(t *testing.T, c *Competition) {
+				move(t, c, OpOpen, StateScramblesOpen, "333-r1-g1")
+				c.Display = DisplayState{}
+				move(t, c, OpHandIn, StateHandIn, "333-r1-g1")
+			},
+			want:    map[string]State{"333-r1-g1": StateScramblesOpen},
+			wantOps: 1,
+		},
+		{
+			name: "every group of the operation",
+			setup: func(t *testing.T, c *Competition) {
+				move(t, c, OpOpen, StateScramblesOpen, "333-r1-g1")
+				c.Display = DisplayState{}
+				op := c.beginOperation(OpOpen, &c.Rounds[0].Groups[0], &c.Rounds[0].Groups[1])
+				c.Rounds[0].Groups[0].transition("333-r1-g1", StateClosed, time.Now())
+				c.Rounds[0].Groups[1].transition("333-r1-g2", StateScramblesOpen, time.Now())
+				c.recordOperation(op)
+			},
+			want:    map[string]State{"333-r1-g1": StateScramblesOpen, "333-r1-g2": StatePending},
+			wantOps: 1,
+		},
+		{
+			name: "attempts",
+			setup: func(t *testing.T, c *Competition) {
+				g := &c.Rounds[1].Groups[0]
+				op := c.beginOperation(OpOpen, g)
+				g.transition(g.ActivityCode, StateScramblesOpen, time.Now())
+				g.Attempts[0].transition(g.Attempts[0].ActivityCode, StateScramblesOpen, time.Now())
+				c.recordOperation(op)
+			},
+			want: map[string]State{"333fm-r1-g1": StatePending, "333fm-r1-g1-a1": StatePending, "333fm-r1-g1-a2": StatePending},
+		},
+		{
+			name: "display not restored",
+			setup: func(t *testing.T, c *Competition) {
+				move(t, c, OpOpen, StateScramblesOpen, "333-r1-g1")
+			},
+			displayErr: errors.New("display unreachable"),
+			wantErr:    true,
+			want:       map[string]State{"333-r1-g1": StateScramblesOpen},
+			wantOps:    1,
+		},
+		{
+			name: "group no longer in the competition",
+			setup: func(t *testing.T, c *Competition) {
+				move(t, c, OpOpen, StateScramblesOpen, "333-r1-g2")
+				c.Rounds[0].Groups = c.Rounds[0].Groups[:1]
+			},
+			wantErr: true,
+			want:    map[string]State{"333-r1-g1": StatePending},
+			wantOps: 1,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			sent := fakeDisplay(t, tt.displayErr)
+			c := undoCompetition()
+			tt.setup(t, c)
+			before := c.Display
+
+			op, err := c.Undo()
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("Undo() error = %v, want error %t", err, tt.wantErr)
+			}
+			if tt.wantIs != nil && !errors.Is(err, tt.wantIs) {
+				t.Fatalf("Undo() error = %v, want %v", err, tt.wantIs)
+			}
+
+			for code, want := range tt.want {
+				got := stateOf(c, code)
+				if got != want {
+					t.Errorf("%s is %s, want %s", code, got, want)
+				}
+			}
+			if len(c.Operations) != tt.wantOps {
+				t.Errorf("%d operations left, want %d", len(c.Operations), tt.wantOps)
+			}
+
+			if err != nil {
+				if c.Display != before {
+					t.Errorf("Display = %+v after a failed undo, want %+v", c.Display, before)
+				}
+				if len(c.pendingAudit) != 0 {
+					t.Error("a failed undo was recorded in the audit log")
+				}
+				return
+			}
+			if c.Display != op.Display {
+				t.Errorf("Display = %+v, want %+v", c.Display, op.Display)
+			}
+			if !slices.Contains(*sent, intermissionPDF()) {
+				t.Errorf("sent %v, want the intermission screen", *sent)
+			}
+			if len(c.pendingAudit) != 1 {
+				t.Errorf("%d audit entries waiting for Save, want 1", len(c.pendingAudit))
+			}
+		})
+	}
+}
+
+// stateOf returns the state of the group or attempt with the activity code
+func stateOf(c *Competition, activityCode string) State {
+	if _, g := c.findGroup(activityCode); g != nil {
+		return g.state()
+	}
+	if _, a := c.findAttempt(activityCode); a != nil {
+		return a.state()
+	}
+	return ""
+}
@@ -0,0 +1,15 @@
+package models
+
+import (
+	"fmt"
+	"io"
+	"os"
+)
+
+// Log receives the progress messages and warnings of the package. Front ends
+// drawing on the terminal point it at their own message area.
+var Log io.Writer = os.Stdout
+
+func logf(format string, args ...any) {
+	fmt.Fprintf(Log, format+"\n", args...)
+}
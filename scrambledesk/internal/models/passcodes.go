@@ -0,0 +1,98 @@
+package models
+
+import (
+	"fmt"
+
+	"github.com/larsjohanfolde/scrambleman/scrambledesk/internal/tnoodle"
+)
+
+// PasscodeReport is what did not match between the groups and the passcode file
+type PasscodeReport struct {
+	// Missing are the scramble sets of groups without a passcode
+	Missing []string `json:",omitempty"`
+	// Unmatched are the lines of the passcode file without a group
+	Unmatched []PasscodeLine `json:",omitempty"`
+	// Duplicates are the scramble sets given another passcode on a later line
+	Duplicates []PasscodeLine `json:",omitempty"`
+}
+
+// PasscodeLine is a scramble set on a line of the passcode file, without its passcode
+type PasscodeLine struct {
+	Set  string
+	Line int
+}
+
+// Problems lists every mismatch of the report on its own
+func (r PasscodeReport) Problems() []error {
+	var problems []error
+	for _, set := range r.Missing {
+		problems = append(problems, fmt.Errorf("%w: %s", ErrPasswordMissing, set))
+	}
+	for _, l := range r.Unmatched {
+		problems = append(problems, fmt.Errorf("%w: %s on line %d of the passcode file", ErrPasscodeUnmatched, l.Set, l.Line))
+	}
+	for _, l := range r.Duplicates {
+		problems = append(problems, fmt.Errorf("%s is in the passcode file more than once, using line %d", l.Set, l.Line))
+	}
+	return problems
+}
+
+// loadPasswords gives every group, or every attempt when scrambled per attempt,
+// the password of its scramble set. What did not match, including groups
+// without a password, is kept in c.Passcodes to be reported as warnings. The
+// groups without a password can still be run once the passcodes are imported.
+func (c *Competition) loadPasswords(file string) error {
+	entries, err := tnoodle.ReadPasscodes(file)
+	if err != nil {
+		return err
+	}
+
+	var report PasscodeReport
+	passwords := make(map[tnoodle.Key]string)
+	for _, e := range entries {
+		if password, ok := passwords[e.Key]; ok && password != e.Password {
+			report.Duplicates = append(report.Duplicates, PasscodeLine{Set: e.Key.String(), Line: e.Line})
+		}
+		passwords[e.Key] = e.Password
+	}
+
+	used := make(map[tnoodle.Key]bool)
+	seal := func(key tnoodle.Key, sealed *string) error {
+		password, ok := passwords[key]
+		if !ok {
+			report.Missing = append(report.Missing, key.String())
+			return nil
+		}
+		used[key] = true
+		*sealed, err = c.seal(password)
+		return err
+	}
+	for i, r := range c.Rounds {
+		for j, g := range r.Groups {
+			group := &c.Rounds[i].Groups[j]
+			if len(g.Attempts) == 0 {
+				err = seal(g.scrambleKey(), &group.SealedPassword)
+				if err != nil {
+					return err
+				}
+				continue
+			}
+			for k, a := range g.Attempts {
+				err = seal(a.scrambleKey(group), &group.Attempts[k].SealedPassword)
+				if err != nil {
+					return err
+				}
+			}
+		}
+	}
+
+	for _, e := range entries {
+		if !used[e.Key] {
+			report.Unmatched = append(report.Unmatched, PasscodeLine{Set: e.Key.String(), Line: e.Line})
+			used[e.Key] = true
+		}
+	}
+
+	c.Passcodes = report
+	return nil
+}
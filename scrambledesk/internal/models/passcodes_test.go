@@ -0,0 +1,45 @@
+package models
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestLoadPasswordsReportsMismatches(t *testing.T) {
+	c := unlocked(t, "correct horse")
+	r := Round{EventId: "333", RoundNumber: 1, ActivityCode: "333-r1"}
+	r.Groups = []Group{r.newGroup(1), r.newGroup(2)}
+	c.Rounds = []Round{r}
+
+	file := filepath.Join(t.TempDir(), "passcodes.txt")
+	err := os.WriteFile(file, []byte("3x3x3 Round 1 Scramble Set A: 1a2b3c\n3x3x3 Round 2 Scramble Set A: 4d5e6f\n"), 0600)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Groups without a password are warnings, the rest of the groups can still run
+	err = c.loadPasswords(file)
+	if err != nil {
+		t.Fatalf("loadPasswords() error = %v", err)
+	}
+
+	password, err := c.open(c.Rounds[0].Groups[0].SealedPassword)
+	if err != nil || password != "1a2b3c" {
+		t.Errorf("password of group 1 = %q, %v, want %q", password, err, "1a2b3c")
+	}
+	if c.Rounds[0].Groups[1].SealedPassword != "" {
+		t.Error("group 2 has a password")
+	}
+
+	missing := c.Rounds[0].Groups[1].ScrambleSet()
+	if len(c.Passcodes.Missing) != 1 || c.Passcodes.Missing[0] != missing {
+		t.Errorf("Missing = %v, want [%s]", c.Passcodes.Missing, missing)
+	}
+	if len(c.Passcodes.Unmatched) != 1 || c.Passcodes.Unmatched[0].Line != 2 {
+		t.Errorf("Unmatched = %v, want line 2", c.Passcodes.Unmatched)
+	}
+	if len(c.Passcodes.Problems()) != 2 {
+		t.Errorf("Problems() = %v, want 2", c.Passcodes.Problems())
+	}
+}
@@ -0,0 +1,241 @@
+package models
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/larsjohanfolde/scrambleman/scrambledesk/config"
+	"github.com/larsjohanfolde/scrambleman/scrambledesk/internal/audit"
+	"github.com/larsjohanfolde/scrambleman/scrambledesk/internal/fsutil"
+)
+
+// MaxBackups is the number of saved states kept in the backup directory of each competition
+const MaxBackups = 20
+
+const backupTimeFormat = "20060102-150405.000"
+
+type Backup struct {
+	Name string
+	Path string
+	Time time.Time
+}
+
+// writeJSONAtomic writes v to a temporary file next to path and renames it over
+// path once it is on disk, so a crash never leaves a half-written file behind
+func writeJSONAtomic(path string, v any) error {
+	dir := filepath.Dir(path)
+	tmp, err := os.CreateTemp(dir, filepath.Base(path)+".*.tmp")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+
+	encoder := json.NewEncoder(tmp)
+	encoder.SetIndent("", "  ")
+	err = encoder.Encode(v)
+	if err != nil {
+		tmp.Close()
+		return err
+	}
+
+	err = tmp.Sync()
+	if err != nil {
+		tmp.Close()
+		return err
+	}
+
+	err = tmp.Close()
+	if err != nil {
+		return err
+	}
+
+	err = os.Rename(tmp.Name(), path)
+	if err != nil {
+		return err
+	}
+	fsutil.SyncDir(dir)
+	return nil
+}
+
+// Reload replaces the competition with the state saved on disk, dropping the
+// changes that were not saved. The passwords stay unlocked.
+func (c *Competition) Reload() error {
+	saved, err := LoadCompetitionFromFile(c.SaveLocation())
+	if err != nil {
+		return err
+	}
+
+	key := c.key
+	*c = *saved
+	c.key = key
+	return nil
+}
+
+// backupDir is where the saved states of the competition are backed up
+func backupDir(competitionID string) string {
+	return filepath.Join(config.BackupDir, competitionID)
+}
+
+// backupPrevious copies the state about to be overwritten into the backup
+// directory of its competition and removes the oldest backups
+func (c *Competition) backupPrevious() error {
+	id, err := savedCompetitionID(c.SaveLocation())
+	if errors.Is(err, os.ErrNotExist) {
+		return nil
+	}
+	if err != nil || id == "" {
+		// A damaged file is still worth keeping
+		id = "unknown"
+	}
+
+	dir := backupDir(id)
+	err = os.MkdirAll(dir, 0700)
+	if err != nil {
+		return err
+	}
+
+	name := fmt.Sprintf("competition-%s.json", time.Now().Format(backupTimeFormat))
+	err = copyFile(c.SaveLocation(), filepath.Join(dir, name))
+	if err != nil {
+		return err
+	}
+
+	backups, err := ListBackups(id)
+	if err != nil {
+		return err
+	}
+	for _, b := range backups[min(len(backups), MaxBackups):] {
+		err = os.Remove(b.Path)
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// savedCompetitionID reads the ID of the competition saved at path
+func savedCompetitionID(path string) (string, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return "", err
+	}
+
+	var saved struct{ ID string }
+	err = json.Unmarshal(data, &saved)
+	if err != nil {
+		return "", err
+	}
+	return saved.ID, nil
+}
+
+// SavedCompetitionID is the ID of the competition the desk has loaded, read without loading it
+func SavedCompetitionID() (string, error) {
+	return savedCompetitionID((&Competition{}).SaveLocation())
+}
+
+// ListBackups returns the backed up states of the competition, newest first
+func ListBackups(competitionID string) ([]Backup, error) {
+	dir := backupDir(competitionID)
+	entries, err := os.ReadDir(dir)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	var backups []Backup
+	for _, e := range entries {
+		name := e.Name()
+		if e.IsDir() || !strings.HasPrefix(name, "competition-") || !strings.HasSuffix(name, ".json") {
+			continue
+		}
+
+		stamp := strings.TrimSuffix(strings.TrimPrefix(name, "competition-"), ".json")
+		t, err := time.ParseInLocation(backupTimeFormat, stamp, time.Local)
+		if err != nil {
+			continue
+		}
+
+		backups = append(backups, Backup{
+			Name: name,
+			Path: filepath.Join(dir, name),
+			Time: t,
+		})
+	}
+
+	sort.Slice(backups, func(i, j int) bool {
+		return backups[i].Time.After(backups[j].Time)
+	})
+	return backups, nil
+}
+
+// RestoreBackup replaces the current state with a backup of the competition,
+// given by name or by its position in ListBackups starting at 1. The state it
+// replaces is not backed up, so the numbers of the backups stay the same.
+func RestoreBackup(competitionID, name string) (*Competition, error) {
+	backups, err := ListBackups(competitionID)
+	if err != nil {
+		return nil, err
+	}
+
+	var backup *Backup
+	n, err := strconv.Atoi(name)
+	if err == nil && n >= 1 && n <= len(backups) {
+		backup = &backups[n-1]
+	}
+	for i, b := range backups {
+		if b.Name == name {
+			backup = &backups[i]
+		}
+	}
+	if backup == nil {
+		return nil, fmt.Errorf("%w: %s", ErrBackupNotFound, name)
+	}
+
+	comp, err := LoadCompetitionFromFile(backup.Path)
+	if err != nil {
+		return nil, fmt.Errorf("could not read backup %s: %w", backup.Name, err)
+	}
+
+	comp.auditOnSave(audit.ActionRestore, "", backup.Name)
+	err = comp.save(false)
+	if err != nil {
+		return nil, err
+	}
+	return comp, nil
+}
+
+func copyFile(src, dst string) error {
+	in, err := os.Open(src)
+	if err != nil {
+		return err
+	}
+	defer in.Close()
+
+	out, err := os.Create(dst)
+	if err != nil {
+		return err
+	}
+
+	_, err = io.Copy(out, in)
+	if err != nil {
+		out.Close()
+		return err
+	}
+
+	err = out.Sync()
+	if err != nil {
+		out.Close()
+		return err
+	}
+	return out.Close()
+}
@@ -0,0 +1,173 @@
+package models
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/larsjohanfolde/scrambleman/scrambledesk/config"
+	"github.com/larsjohanfolde/scrambleman/scrambledesk/internal/audit"
+	"github.com/larsjohanfolde/scrambleman/scrambledesk/internal/pdf"
+	"github.com/larsjohanfolde/scrambleman/scrambledesk/internal/tnoodle"
+	"github.com/larsjohanfolde/scrambleman/scrambledesk/internal/upload"
+)
+
+// CheckResult is the outcome of one readiness check, Err is nil when it passed
+type CheckResult struct {
+	Name string
+	Err  error
+}
+
+// Readiness goes through everything the desk needs during the competition:
+// the scramble sets are compared with the WCIF, the TNoodle JSON and the
+// passcode file, every scramble set is decrypted with its password and
+// watermarked, and the avatars, fonts, images and templates used for the
+// display are looked up. Finally the connection to the display is tested.
+func (c *Competition) Readiness() []CheckResult {
+	var results []CheckResult
+
+	scrambles, err := tnoodle.ScanDir(c.ScrambleDir())
+	if err != nil {
+		results = append(results, CheckResult{Name: "Scramble sets", Err: err})
+	} else {
+		results = append(results, c.checkScrambleFiles(scrambles)...)
+		results = append(results, c.checkPasscodes()...)
+		if err = c.Unlock(); err != nil {
+			results = append(results, CheckResult{Name: "Passphrase", Err: err})
+		} else if tmp, err := os.MkdirTemp("", "scrambledesk-check-*"); err != nil {
+			results = append(results, CheckResult{Name: "Scramble sets", Err: err})
+		} else {
+			defer os.RemoveAll(tmp)
+			results = append(results, c.checkDecryption(scrambles, tmp)...)
+		}
+	}
+
+	results = append(results, c.checkAvatars())
+
+	files := []struct{ name, path string }{
+		{"Regular font", filepath.Join(config.FontDir, "HackNerdFont-Regular.ttf")},
+		{"Bold font", filepath.Join(config.FontDir, "HackNerdFont-Bold.ttf")},
+		{"Placeholder image", filepath.Join(config.AppDataDir, "placeholder.jpg")},
+		{"Intermission screen", intermissionPDF()},
+	}
+	for _, f := range files {
+		results = append(results, CheckResult{Name: f.name, Err: checkFile(f.path)})
+	}
+
+	results = append(results, CheckResult{
+		Name: fmt.Sprintf("Display at %s", config.IP),
+		Err:  upload.Ping(config.IP, 5*time.Second),
+	})
+	return results
+}
+
+// checkScrambleFiles reports every mismatch between the scramble sets, the
+// groups, the WCIF and the TNoodle JSON on its own
+func (c *Competition) checkScrambleFiles(scrambles *tnoodle.Index) []CheckResult {
+	name := "Scramble sets match the WCIF"
+	if c.InterchangeFile() != "" {
+		name = "Scramble sets match the WCIF and the TNoodle JSON"
+	}
+	problems := c.scrambleProblems(scrambles, c.InterchangeFile())
+	if len(problems) == 0 {
+		return []CheckResult{{Name: name}}
+	}
+	results := make([]CheckResult, len(problems))
+	for i, p := range problems {
+		results[i] = CheckResult{Name: name, Err: p}
+	}
+	return results
+}
+
+// checkPasscodes reports the groups without a passcode and the passcodes
+// without a group found when the passwords were loaded
+func (c *Competition) checkPasscodes() []CheckResult {
+	problems := c.Passcodes.Problems()
+	if len(problems) == 0 {
+		return []CheckResult{{Name: "Passcodes match the groups"}}
+	}
+	results := make([]CheckResult, len(problems))
+	for i, p := range problems {
+		results[i] = CheckResult{Name: "Passcodes match the groups", Err: p}
+	}
+	return results
+}
+
+// checkDecryption decrypts the scramble set of every group, or of every attempt
+// when scrambled per attempt, into dir. Every decryption is recorded in the audit log.
+func (c *Competition) checkDecryption(scrambles *tnoodle.Index, dir string) []CheckResult {
+	var results []CheckResult
+	for _, r := range c.Rounds {
+		for _, g := range r.Groups {
+			if len(g.Attempts) == 0 {
+				results = append(results, c.checkScrambleSet(scrambles, g.ActivityCode, g.scrambleKey(), g.SealedPassword, dir))
+				continue
+			}
+			for _, a := range g.Attempts {
+				results = append(results, c.checkScrambleSet(scrambles, a.ActivityCode, a.scrambleKey(&g), a.SealedPassword, dir))
+			}
+		}
+	}
+	return results
+}
+
+func (c *Competition) checkScrambleSet(scrambles *tnoodle.Index, activity string, key tnoodle.Key, sealed, dir string) CheckResult {
+	result := CheckResult{Name: key.String()}
+	set, err := scrambles.Lookup(key)
+	if err != nil {
+		result.Err = err
+		return result
+	}
+	password, err := c.open(sealed)
+	if err != nil {
+		result.Err = err
+		return result
+	}
+
+	output := filepath.Join(dir, filepath.Base(set.Path))
+	defer os.Remove(output)
+	result.Err = pdf.DecryptPDFTo(set.Path, output, password)
+	if result.Err != nil {
+		return result
+	}
+	c.auditLog(audit.ActionCheck, activity, fmt.Sprintf("%s, session %s", key, sessionID))
+	if !c.Watermark.Disabled {
+		result.Err = pdf.Watermark(output, watermarkText(key.String(), time.Now()), c.Watermark.style())
+	}
+	return result
+}
+
+// checkAvatars reports the competitors with an avatar on the WCA website that has not been downloaded
+func (c *Competition) checkAvatars() CheckResult {
+	result := CheckResult{Name: "Avatars"}
+	var missing []string
+	for _, p := range c.Persons {
+		if p.Avatar.Url == "" {
+			continue
+		}
+		if checkFile(p.ImagePath()+".jpg") != nil {
+			missing = append(missing, p.Name)
+		}
+	}
+	if len(missing) > 0 {
+		result.Err = fmt.Errorf("%d missing: %s", len(missing), strings.Join(missing, ", "))
+	}
+	return result
+}
+
+func checkFile(path string) error {
+	info, err := os.Stat(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return fmt.Errorf("%s not found", path)
+	}
+	if err != nil {
+		return err
+	}
+	if info.Size() == 0 {
+		return fmt.Errorf("%s is empty", path)
+	}
+	return nil
+}
@@ -0,0 +1,116 @@
+package models
+
+import (
+	"fmt"
+	"slices"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+type Room struct {
+	ID        int
+	Name      string
+	VenueID   int
+	VenueName string
+}
+
+func (r Room) String() string {
+	if r.VenueName == "" {
+		return r.Name
+	}
+	return fmt.Sprintf("%s (%s)", r.Name, r.VenueName)
+}
+
+func (c *Competition) Room(id int) *Room {
+	for i, r := range c.Rooms {
+		if r.ID == id {
+			return &c.Rooms[i]
+		}
+	}
+	return nil
+}
+
+// SelectRooms sets the rooms this desk manages. Rooms are given by ID or name,
+// an empty selection or "all" means every room.
+func (c *Competition) SelectRooms(selection []string) error {
+	var ids []int
+	for _, s := range selection {
+		s = strings.TrimSpace(s)
+		if s == "" {
+			continue
+		}
+		if strings.EqualFold(s, "all") {
+			c.SelectedRooms = nil
+			return nil
+		}
+
+		room := c.findRoom(s)
+		if room == nil {
+			return fmt.Errorf("room not found: %s", s)
+		}
+		if !slices.Contains(ids, room.ID) {
+			ids = append(ids, room.ID)
+		}
+	}
+	c.SelectedRooms = ids
+	return nil
+}
+
+func (c *Competition) findRoom(s string) *Room {
+	id, err := strconv.Atoi(s)
+	if err == nil {
+		return c.Room(id)
+	}
+	for i, r := range c.Rooms {
+		if strings.EqualFold(r.Name, s) {
+			return &c.Rooms[i]
+		}
+	}
+	return nil
+}
+
+// roomSelected reports whether the round takes place in one of the rooms this desk manages
+func (c *Competition) roomSelected(r Round) bool {
+	if len(c.SelectedRooms) == 0 {
+		return true
+	}
+	return slices.Contains(c.SelectedRooms, r.RoomID)
+}
+
+// roundGroupCount counts the groups of a round across every room it takes place in
+func (c *Competition) roundGroupCount(activityCode string) int {
+	count := 0
+	for _, r := range c.Rounds {
+		if r.ActivityCode == activityCode {
+			count += len(r.Groups)
+		}
+	}
+	return count
+}
+
+// roundRooms returns the round in every room it takes place in
+func (c *Competition) roundRooms(activityCode string) []*Round {
+	var rounds []*Round
+	for i, r := range c.Rounds {
+		if r.ActivityCode == activityCode {
+			rounds = append(rounds, &c.Rounds[i])
+		}
+	}
+	return rounds
+}
+
+// roundGroups returns the groups of a round across every room it takes place
+// in, ordered by group number
+func (c *Competition) roundGroups(activityCode string) []*Group {
+	var groups []*Group
+	for _, r := range c.roundRooms(activityCode) {
+		for j := range r.Groups {
+			groups = append(groups, &r.Groups[j])
+		}
+	}
+	sort.SliceStable(groups, func(i, j int) bool {
+		return groups[i].GroupNumber < groups[j].GroupNumber
+	})
+	return groups
+}
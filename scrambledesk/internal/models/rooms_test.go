@@ -0,0 +1,52 @@
+package models
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/larsjohanfolde/scrambleman/scrambledesk/internal/tnoodle"
+)
+
+// splitRound returns a competition holding 333-r2 in two rooms, with group 1
+// scheduled in the first and group 2 in the second
+func splitRound() *Competition {
+	round := func(room, group int) Round {
+		r := Round{EventId: "333", RoundNumber: 2, ActivityCode: "333-r2", RoomID: room}
+		r.Groups = []Group{r.newGroup(group)}
+		return r
+	}
+	return &Competition{Rounds: []Round{round(1, 1), round(2, 2)}}
+}
+
+func TestLoadAdvancedRoundDataSplitAcrossRooms(t *testing.T) {
+	dir := t.TempDir()
+	for _, set := range []string{"A", "B", "C"} {
+		err := os.WriteFile(filepath.Join(dir, "3x3x3 Round 2 Scramble Set "+set+".pdf"), nil, 0o600)
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+	scrambles, err := tnoodle.ScanDir(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	c := splitRound()
+	c.loadAdvancedRoundData(scrambles)
+
+	for i, want := range []int{2, 1} {
+		r := c.Rounds[i]
+		if r.GroupCount != len(r.Groups) || len(r.Groups) != want {
+			t.Errorf("room %d: GroupCount = %d with %d groups, want %d", r.RoomID, r.GroupCount, len(r.Groups), want)
+		}
+	}
+
+	var numbers []int
+	for _, g := range c.roundGroups("333-r2") {
+		numbers = append(numbers, g.GroupNumber)
+	}
+	if len(numbers) != 3 || numbers[0] != 1 || numbers[1] != 2 || numbers[2] != 3 {
+		t.Errorf("roundGroups() = %v, want [1 2 3]", numbers)
+	}
+}
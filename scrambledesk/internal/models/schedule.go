@@ -0,0 +1,170 @@
+package models
+
+import "time"
+
+// GroupTiming compares a group's planned window with when it actually ran, or
+// is projected to run if it has not finished yet. Groups scrambled per attempt
+// are timed per attempt.
+type GroupTiming struct {
+	Group          *Group
+	Attempt        *Attempt
+	Round          *Round
+	PlannedStart   time.Time
+	PlannedEnd     time.Time
+	ActualStart    time.Time
+	ActualEnd      time.Time
+	ProjectedStart time.Time
+	ProjectedEnd   time.Time
+}
+
+// StartDelay is how late the group started, or is projected to start. Negative when early.
+func (t GroupTiming) StartDelay() time.Duration {
+	if !t.ActualStart.IsZero() {
+		return t.ActualStart.Sub(t.PlannedStart)
+	}
+	return t.ProjectedStart.Sub(t.PlannedStart)
+}
+
+// RoundRisk is a round projected to end after its planned end
+type RoundRisk struct {
+	Round        *Round
+	ProjectedEnd time.Time
+}
+
+func (r RoundRisk) Overrun() time.Duration {
+	return r.ProjectedEnd.Sub(r.Round.EndTime)
+}
+
+// ScheduleStatus describes how the day is going compared to the schedule
+type ScheduleStatus struct {
+	Time    time.Time
+	Day     time.Time
+	Current *Group
+	Next    *Group
+	// CurrentAttempt and NextAttempt are set when the group is scrambled per attempt
+	CurrentAttempt *Attempt
+	NextAttempt    *Attempt
+	// Delay is how far behind schedule we are, negative when ahead
+	Delay        time.Duration
+	Groups       []GroupTiming
+	PlannedEnd   time.Time
+	ProjectedEnd time.Time
+	AtRisk       []RoundRisk
+}
+
+// ScheduleStatus compares the groups of the managed rooms against their planned
+// times. Groups still to run are projected to start when the previous group
+// ends, but never before their planned start.
+func (c *Competition) ScheduleStatus(now time.Time) ScheduleStatus {
+	c.SortRounds()
+	status := ScheduleStatus{Time: now}
+	status.Current = c.CurrentGroup()
+	if status.Current != nil {
+		status.CurrentAttempt = status.Current.activeAttempt()
+	}
+	status.Next, status.NextAttempt = c.Next()
+
+	slots := c.timeline()
+	var current, next *slot
+	for i, s := range slots {
+		if s.group == status.Current && s.attempt == status.CurrentAttempt {
+			current = &slots[i]
+		}
+		if s.group == status.Next && s.attempt == status.NextAttempt {
+			next = &slots[i]
+		}
+	}
+
+	// Report on today, or the next day with groups when nothing runs today
+	status.Day = startOfDay(now)
+	if !hasSlotsOn(slots, status.Day) {
+		switch {
+		case current != nil:
+			status.Day = startOfDay(current.start())
+		case next != nil:
+			status.Day = startOfDay(next.start())
+		}
+	}
+
+	cursor := now
+	projectedEnds := make(map[*Round]time.Time)
+	var rounds []*Round
+	for _, s := range slots {
+		if !startOfDay(s.start()).Equal(status.Day) {
+			continue
+		}
+		l := s.lifecycle()
+		duration := s.end().Sub(s.start())
+		timing := GroupTiming{
+			Group:        s.group,
+			Attempt:      s.attempt,
+			Round:        s.round,
+			PlannedStart: s.start(),
+			PlannedEnd:   s.end(),
+			ActualStart:  l.OpenedAt(),
+		}
+
+		switch {
+		case l.State == StateSkipped:
+			continue
+		case l.Done():
+			timing.ActualEnd = l.ClosedAt()
+		case l.Active():
+			timing.ProjectedStart = timing.ActualStart
+			timing.ProjectedEnd = later(timing.ActualStart.Add(duration), now)
+			cursor = later(cursor, timing.ProjectedEnd)
+		default:
+			timing.ProjectedStart = later(cursor, s.start())
+			timing.ProjectedEnd = timing.ProjectedStart.Add(duration)
+			cursor = timing.ProjectedEnd
+		}
+
+		if _, ok := projectedEnds[s.round]; !ok {
+			rounds = append(rounds, s.round)
+		}
+		projectedEnds[s.round] = later(projectedEnds[s.round], timing.ProjectedEnd)
+		status.PlannedEnd = later(status.PlannedEnd, s.end())
+		status.ProjectedEnd = later(status.ProjectedEnd, later(timing.ProjectedEnd, timing.ActualEnd))
+		status.Groups = append(status.Groups, timing)
+	}
+
+	for _, r := range rounds {
+		projectedEnd := projectedEnds[r]
+		if !projectedEnd.IsZero() && projectedEnd.After(r.EndTime) {
+			status.AtRisk = append(status.AtRisk, RoundRisk{Round: r, ProjectedEnd: projectedEnd})
+		}
+	}
+
+	switch {
+	case current != nil:
+		// A group running past its planned end keeps pushing the schedule back
+		status.Delay = current.lifecycle().OpenedAt().Sub(current.start())
+		if overrun := now.Sub(current.end()); overrun > status.Delay {
+			status.Delay = overrun
+		}
+	case next != nil && now.After(next.start()):
+		status.Delay = now.Sub(next.start())
+	}
+	return status
+}
+
+func hasSlotsOn(slots []slot, day time.Time) bool {
+	for _, s := range slots {
+		if startOfDay(s.start()).Equal(day) {
+			return true
+		}
+	}
+	return false
+}
+
+func startOfDay(t time.Time) time.Time {
+	t = t.Local()
+	return time.Date(t.Year(), t.Month(), t.Day(), 0, 0, 0, 0, time.Local)
+}
+
+func later(a, b time.Time) time.Time {
+	if b.After(a) {
+		return b
+	}
+	return a
+}
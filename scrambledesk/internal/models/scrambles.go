@@ -0,0 +1,382 @@
+package models
+
+import (
+	"archive/zip"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+
+	"github.com/larsjohanfolde/scrambleman/scrambledesk/config"
+	"github.com/larsjohanfolde/scrambleman/scrambledesk/internal/fsutil"
+	"github.com/larsjohanfolde/scrambleman/scrambledesk/internal/tnoodle"
+)
+
+const (
+	displayPDFDir = "Computer Display PDFs"
+	passcodeFile  = "Passcodes - SECRET.txt"
+	tnoodleJSON   = "TNoodle.json"
+)
+
+// maxImportSize caps the uncompressed size of everything extracted from the TNoodle zip
+const maxImportSize = 1 << 30
+
+// maxZipDepth is how deep zips inside the TNoodle zip are read. TNoodle puts
+// the computer display zip one level deep.
+const maxZipDepth = 2
+
+// scrambleFiles are where the scramble sets and their passcodes are read from
+type scrambleFiles struct {
+	dir       string
+	passcodes string
+}
+
+// scrambleFiles are the files of the competition's scramble sets
+func (c *Competition) scrambleFiles() scrambleFiles {
+	return scrambleFiles{dir: c.ScrambleDir(), passcodes: c.PasscodeFile()}
+}
+
+// importedFiles are the files of scramble sets imported into dir
+func importedFiles(dir string) scrambleFiles {
+	return scrambleFiles{
+		dir:       filepath.Join(dir, displayPDFDir),
+		passcodes: filepath.Join(dir, passcodeFile),
+	}
+}
+
+// importDir is where the scramble sets of the competition are imported to
+func (c *Competition) importDir() string {
+	return filepath.Join(config.ScramblesDir, c.ID)
+}
+
+// ScrambleDir is where the computer display PDFs are. Imported scramble sets
+// are preferred over a folder extracted next to where the desk is launched.
+func (c *Competition) ScrambleDir() string {
+	dir := filepath.Join(c.importDir(), displayPDFDir)
+	if _, err := os.Stat(dir); err == nil {
+		return dir
+	}
+	return fmt.Sprintf("./%s - %s", c.Name, displayPDFDir)
+}
+
+// PasscodeFile is the file with the passcodes of the computer display PDFs
+// extracted next to where the desk is launched. The passcodes of imported
+// scramble sets are not kept in a file.
+func (c *Competition) PasscodeFile() string {
+	return fmt.Sprintf("%s - Computer Display PDF Passcodes - SECRET.txt", c.Name)
+}
+
+// InterchangeFile is the JSON TNoodle generated with the scramble sets, empty
+// if none was imported. Only the imported JSON is used, a file that happens to
+// be next to where the desk is launched may belong to another competition.
+func (c *Competition) InterchangeFile() string {
+	file := filepath.Join(c.importDir(), tnoodleJSON)
+	if _, err := os.Stat(file); err != nil {
+		return ""
+	}
+	return file
+}
+
+// scrambleZip extracts the files to import from the TNoodle zip into dir
+type scrambleZip struct {
+	dir string
+	// limit is how many uncompressed bytes may be extracted, size how many were
+	limit int64
+	size  int64
+
+	pdfs        int
+	passcodes   string
+	interchange string
+	seen        map[string]bool
+	nested      int
+}
+
+// ImportScrambles copies the computer display PDFs and the JSON from the zip
+// generated by TNoodle into the app data directory and loads them and the
+// passcodes into the rounds. The entries are streamed to disk, the zip may hold
+// at most maxImportSize uncompressed. The passcodes are only kept sealed in the
+// competition, save it to keep them. An earlier import is only replaced once
+// the new one has loaded. Returns the number of scramble sets imported.
+func (c *Competition) ImportScrambles(zipPath string) (int, error) {
+	if c.ID == "" {
+		return 0, errors.New("the competition has no ID")
+	}
+
+	archive, err := zip.OpenReader(zipPath)
+	if err != nil {
+		return 0, err
+	}
+	defer archive.Close()
+
+	tmp, err := os.MkdirTemp(config.ScramblesDir, c.ID+".*.tmp")
+	if err != nil {
+		return 0, err
+	}
+	defer os.RemoveAll(tmp)
+
+	err = os.Mkdir(filepath.Join(tmp, displayPDFDir), 0755)
+	if err != nil {
+		return 0, err
+	}
+
+	// The zip may be the computer display PDFs on their own
+	isDisplayZip := strings.Contains(strings.ToLower(filepath.Base(zipPath)), strings.ToLower(displayPDFDir))
+	contents := &scrambleZip{dir: tmp, limit: maxImportSize, seen: make(map[string]bool)}
+	err = contents.read(&archive.Reader, isDisplayZip, 0)
+	if err != nil {
+		return 0, err
+	}
+	if contents.pdfs == 0 {
+		return 0, fmt.Errorf("%w in %s", ErrNoScrambleSets, zipPath)
+	}
+	if contents.passcodes == "" {
+		return 0, fmt.Errorf("%w: no passcode file in %s", ErrPasswordMissing, zipPath)
+	}
+
+	err = c.loadScrambles(importedFiles(tmp))
+	if err != nil {
+		return 0, err
+	}
+	err = os.Remove(filepath.Join(tmp, passcodeFile))
+	if err != nil {
+		return 0, err
+	}
+
+	// Move the earlier import aside rather than removing it, so a crash never leaves neither
+	previous := c.importDir() + ".previous"
+	err = os.RemoveAll(previous)
+	if err != nil {
+		return 0, err
+	}
+	err = os.Rename(c.importDir(), previous)
+	if err != nil && !errors.Is(err, fs.ErrNotExist) {
+		return 0, err
+	}
+	err = os.Rename(tmp, c.importDir())
+	if err != nil {
+		os.Rename(previous, c.importDir())
+		return 0, err
+	}
+	fsutil.SyncDir(config.ScramblesDir)
+	os.RemoveAll(previous)
+
+	return contents.pdfs, nil
+}
+
+// read extracts the computer display PDFs, the passcode file and the TNoodle
+// JSON from the zip. The PDFs are also read from a computer display zip inside
+// the zip, which is depth zips deep.
+func (s *scrambleZip) read(archive *zip.Reader, isDisplayZip bool, depth int) error {
+	for _, f := range archive.File {
+		if f.FileInfo().IsDir() {
+			continue
+		}
+
+		name := path.Base(f.Name)
+		inDisplay := isDisplayZip || strings.Contains(strings.ToLower(f.Name), strings.ToLower(displayPDFDir))
+		ext := strings.ToLower(path.Ext(name))
+		isPasscodes := ext == ".txt" && strings.Contains(strings.ToLower(name), "passcodes")
+		// TNoodle puts the JSON in the Interchange folder, other JSON files are not its
+		isInterchange := ext == ".json" && isInterchangeDir(path.Dir(f.Name))
+
+		if !isPasscodes && !isInterchange && !(inDisplay && (ext == ".pdf" || ext == ".zip")) {
+			continue
+		}
+		// Flag 0x1 marks an encrypted entry, which archive/zip cannot read
+		if f.Flags&0x1 != 0 {
+			return fmt.Errorf("%w: %s", ErrZipEncrypted, f.Name)
+		}
+
+		switch {
+		case isPasscodes:
+			s.passcodes = name
+			err := s.extract(f, filepath.Join(s.dir, passcodeFile), 0600)
+			if err != nil {
+				return err
+			}
+		case isInterchange:
+			if s.interchange != "" {
+				return fmt.Errorf("%s and %s are both in the Interchange folder", s.interchange, name)
+			}
+			s.interchange = name
+			err := s.extract(f, filepath.Join(s.dir, tnoodleJSON), 0644)
+			if err != nil {
+				return err
+			}
+		case ext == ".zip":
+			err := s.readNested(f, depth+1)
+			if err != nil {
+				return err
+			}
+		default:
+			if s.seen[name] {
+				return fmt.Errorf("%s is in the zip more than once", name)
+			}
+			s.seen[name] = true
+			err := s.extract(f, filepath.Join(s.dir, displayPDFDir, name), 0644)
+			if err != nil {
+				return err
+			}
+			s.pdfs++
+		}
+	}
+	return nil
+}
+
+// readNested extracts a zip inside the TNoodle zip to read the PDFs in it
+func (s *scrambleZip) readNested(f *zip.File, depth int) error {
+	if depth > maxZipDepth {
+		return fmt.Errorf("%s is nested more than %d zips deep", f.Name, maxZipDepth)
+	}
+
+	s.nested++
+	name := filepath.Join(s.dir, fmt.Sprintf("nested-%d.zip", s.nested))
+	err := s.extract(f, name, 0600)
+	if err != nil {
+		return err
+	}
+	defer os.Remove(name)
+
+	nested, err := zip.OpenReader(name)
+	if err != nil {
+		return fmt.Errorf("could not read %s: %w", f.Name, err)
+	}
+	defer nested.Close()
+	return s.read(&nested.Reader, true, depth)
+}
+
+// isInterchangeDir reports whether dir is the Interchange folder of a TNoodle zip
+func isInterchangeDir(dir string) bool {
+	return strings.EqualFold(path.Base(dir), "Interchange")
+}
+
+// extract streams the file from the zip to dst, failing once more than the
+// limit has been extracted from the TNoodle zip
+func (s *scrambleZip) extract(f *zip.File, dst string, perm fs.FileMode) error {
+	r, err := f.Open()
+	if err != nil {
+		return fmt.Errorf("could not read %s: %w", f.Name, err)
+	}
+	defer r.Close()
+
+	out, err := os.OpenFile(dst, os.O_WRONLY|os.O_CREATE|os.O_TRUNC, perm)
+	if err != nil {
+		return err
+	}
+	n, err := io.Copy(out, io.LimitReader(r, s.limit-s.size+1))
+	s.size += n
+	if err != nil {
+		out.Close()
+		return fmt.Errorf("could not read %s: %w", f.Name, err)
+	}
+	if s.size > s.limit {
+		out.Close()
+		return fmt.Errorf("the zip is larger than %d MB uncompressed, stopped at %s", s.limit>>20, f.Name)
+	}
+	return out.Close()
+}
+
+// loadScrambles adds groups for the scramble sets of rounds without groups in
+// the schedule, checks that every group has its scramble set and loads the passwords
+func (c *Competition) loadScrambles(files scrambleFiles) error {
+	scrambles, err := tnoodle.ScanDir(files.dir)
+	if errors.Is(err, fs.ErrNotExist) {
+		// The scramble sets can be imported after the competition is loaded
+		logf("No scramble sets found, import them with -import-scrambles")
+		c.AssignCompetitors()
+		c.AssignStaff()
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+
+	// Rounds without groups in the schedule get one group per scramble set
+	c.loadInitialRoundData(scrambles)
+	c.loadAdvancedRoundData(scrambles)
+
+	for i := range c.Rounds {
+		c.Rounds[i].fillAttempts()
+		c.Rounds[i].splitGroupTimes()
+	}
+
+	c.AssignCompetitors()
+	c.AssignStaff()
+	if _, err := os.Stat(files.passcodes); errors.Is(err, fs.ErrNotExist) {
+		// Imported passcodes are only kept sealed in the competition
+		logf("No passcodes found, import the scramble sets again with -import-scrambles")
+		return nil
+	}
+	return c.loadPasswords(files.passcodes)
+}
+
+// ScrambleProblems compares the scramble sets on disk with the groups, the
+// scrambleSetCount of the WCIF and the TNoodle JSON when one was imported
+func (c *Competition) ScrambleProblems() []error {
+	scrambles, err := tnoodle.ScanDir(c.ScrambleDir())
+	if err != nil {
+		return []error{err}
+	}
+	return c.scrambleProblems(scrambles, c.InterchangeFile())
+}
+
+// scrambleProblems compares the scramble sets with the groups, the WCIF and
+// the TNoodle JSON at interchangeFile, which is skipped when empty
+func (c *Competition) scrambleProblems(scrambles *tnoodle.Index, interchangeFile string) []error {
+	problems := c.checkScrambleSets(scrambles)
+	if interchangeFile == "" {
+		return append(problems, c.checkScrambleCounts(scrambles, nil)...)
+	}
+	interchange, err := tnoodle.ReadInterchange(interchangeFile)
+	if err != nil {
+		return append(problems, err)
+	}
+	return append(problems, c.checkScrambleCounts(scrambles, interchange)...)
+}
+
+// checkScrambleCounts reports rounds where the WCIF scrambleSetCount, the
+// TNoodle JSON and the files on disk do not agree on the scramble sets.
+// The JSON is skipped when nil.
+func (c *Competition) checkScrambleCounts(scrambles *tnoodle.Index, interchange *tnoodle.Interchange) []error {
+	var problems []error
+	seen := make(map[string]bool)
+	for _, r := range c.Rounds {
+		// Rounds split across rooms share their scramble sets
+		if seen[r.ActivityCode] {
+			continue
+		}
+		seen[r.ActivityCode] = true
+
+		name := tnoodle.RoundName(r.EventId, r.RoundNumber)
+		onDisk := len(scrambles.Sets(r.EventId, r.RoundNumber))
+		if r.ScrambleSetCount > 0 && onDisk != r.ScrambleSetCount {
+			problems = append(problems, fmt.Errorf("%w: %s has %d in the WCIF but %d on disk", ErrScrambleSetCount, name, r.ScrambleSetCount, onDisk))
+		}
+		if interchange == nil {
+			continue
+		}
+		inJSON := len(interchange.Sets(r.EventId, r.RoundNumber))
+		if r.ScrambleSetCount > 0 && inJSON != r.ScrambleSetCount {
+			problems = append(problems, fmt.Errorf("%w: %s has %d in the WCIF but %d in the TNoodle JSON", ErrScrambleSetCount, name, r.ScrambleSetCount, inJSON))
+		}
+	}
+
+	if interchange == nil {
+		return problems
+	}
+	for _, key := range interchange.Keys {
+		if _, err := scrambles.Lookup(key); errors.Is(err, tnoodle.ErrMissing) {
+			problems = append(problems, fmt.Errorf("%w: %s is in the TNoodle JSON but not on disk", tnoodle.ErrMissing, key))
+		}
+	}
+	for _, key := range scrambles.Keys() {
+		if !interchange.Contains(key) {
+			problems = append(problems, fmt.Errorf("%w: %s is on disk but not in the TNoodle JSON", ErrScrambleSetCount, key))
+		}
+	}
+	return problems
+}
@@ -0,0 +1,113 @@
+package models
+
+import (
+	"archive/zip"
+	"bytes"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// zipOf returns a zip holding the files, by name
+func zipOf(t *testing.T, files map[string][]byte) []byte {
+	t.Helper()
+	var buf bytes.Buffer
+	w := zip.NewWriter(&buf)
+	for name, data := range files {
+		f, err := w.Create(name)
+		if err != nil {
+			t.Fatal(err)
+		}
+		_, err = f.Write(data)
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+	err := w.Close()
+	if err != nil {
+		t.Fatal(err)
+	}
+	return buf.Bytes()
+}
+
+// nestedZip puts the zip depth zips deep
+func nestedZip(t *testing.T, data []byte, depth int) []byte {
+	for range depth {
+		data = zipOf(t, map[string][]byte{"Computer Display PDFs.zip": data})
+	}
+	return data
+}
+
+func TestScrambleZipRead(t *testing.T) {
+	pdf := bytes.Repeat([]byte("%PDF"), 256)
+	display := zipOf(t, map[string][]byte{
+		"3x3x3 Round 1 Scramble Set A.pdf": pdf,
+		"3x3x3 Round 1 Scramble Set B.pdf": pdf,
+	})
+
+	tests := []struct {
+		name    string
+		zip     []byte
+		limit   int64
+		pdfs    int
+		wantErr string
+	}{
+		{"folder", zipOf(t, map[string][]byte{
+			"Comp/Computer Display PDFs/3x3x3 Round 1 Scramble Set A.pdf": pdf,
+			"Comp/Comp - Computer Display PDF Passcodes - SECRET.txt":     []byte("3x3x3 Round 1 Scramble Set A: 1a2b3c"),
+			"Comp/Interchange/Comp.json":                                  []byte("{}"),
+			"Comp/Printing/3x3x3 Round 1.pdf":                             pdf,
+		}), maxImportSize, 1, ""},
+		{"nested zip", zipOf(t, map[string][]byte{"Comp/Computer Display PDFs.zip": display}), maxImportSize, 2, ""},
+		{"nested twice", nestedZip(t, display, 2), maxImportSize, 2, ""},
+		{"nested too deep", nestedZip(t, display, maxZipDepth+1), maxImportSize, 0, "nested more than"},
+		{"too large", zipOf(t, map[string][]byte{"Comp/Computer Display PDFs.zip": display}), int64(len(display) + len(pdf)), 0, "larger than"},
+		{"two JSON files", zipOf(t, map[string][]byte{
+			"Comp/Interchange/Comp.json":  []byte("{}"),
+			"Comp/Interchange/Other.json": []byte("{}"),
+		}), maxImportSize, 0, "both in the Interchange folder"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			dir := t.TempDir()
+			err := os.Mkdir(filepath.Join(dir, displayPDFDir), 0755)
+			if err != nil {
+				t.Fatal(err)
+			}
+			archive, err := zip.NewReader(bytes.NewReader(tt.zip), int64(len(tt.zip)))
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			s := &scrambleZip{dir: dir, limit: tt.limit, seen: make(map[string]bool)}
+			err = s.read(archive, false, 0)
+			if tt.wantErr != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+					t.Fatalf("read() error = %v, want %q", err, tt.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			if s.pdfs != tt.pdfs {
+				t.Errorf("read %d PDFs, want %d", s.pdfs, tt.pdfs)
+			}
+			entries, err := os.ReadDir(filepath.Join(dir, displayPDFDir))
+			if err != nil {
+				t.Fatal(err)
+			}
+			if len(entries) != tt.pdfs {
+				t.Errorf("extracted %d PDFs, want %d", len(entries), tt.pdfs)
+			}
+			// Nested zips are removed once read
+			matches, _ := filepath.Glob(filepath.Join(dir, "*.zip"))
+			if len(matches) > 0 {
+				t.Errorf("nested zips left in the import: %v", matches)
+			}
+		})
+	}
+}
@@ -0,0 +1,197 @@
+package models
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/pbkdf2"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"errors"
+	"fmt"
+)
+
+// PassphraseEnv is read for the passphrase before asking for it
+const PassphraseEnv = "SCRAMBLEDESK_PASSPHRASE"
+
+const (
+	sealIterations = 600_000
+	sealCheck      = "scrambledesk"
+)
+
+// Passphrase asks for the passphrase sealing the scramble passwords. New is
+// true when the passphrase is chosen rather than entered again. Set by the front end.
+var Passphrase func(new bool) (string, error)
+
+// Sealing describes how the scramble passwords are sealed. Only the salt is
+// stored, the key is derived from the passphrase when the desk starts.
+type Sealing struct {
+	Salt       []byte
+	Iterations int
+	// Check is a known value sealed with the key, to tell a wrong passphrase apart
+	Check string
+}
+
+// Unlock derives the key sealing the scramble passwords from the passphrase.
+// Competitions without passwords sealed yet get a new salt.
+func (c *Competition) Unlock() error {
+	if c.key != nil {
+		return nil
+	}
+	if Passphrase == nil {
+		return ErrNoPassphrase
+	}
+
+	sealing := c.Sealing
+	if sealing == nil {
+		salt := make([]byte, 16)
+		_, err := rand.Read(salt)
+		if err != nil {
+			return err
+		}
+		sealing = &Sealing{Salt: salt, Iterations: sealIterations}
+	}
+
+	passphrase, err := Passphrase(c.Sealing == nil)
+	if err != nil {
+		return err
+	}
+	if passphrase == "" {
+		return ErrNoPassphrase
+	}
+
+	key, err := pbkdf2.Key(sha256.New, passphrase, sealing.Salt, sealing.Iterations, 32)
+	if err != nil {
+		return err
+	}
+
+	if sealing.Check == "" {
+		sealing.Check, err = sealWith(key, sealCheck)
+		if err != nil {
+			return err
+		}
+	} else if check, err := openWith(key, sealing.Check); err != nil || check != sealCheck {
+		return ErrWrongPassphrase
+	}
+
+	c.Sealing = sealing
+	c.key = key
+	return nil
+}
+
+// seal encrypts the password with the key derived from the passphrase
+func (c *Competition) seal(password string) (string, error) {
+	err := c.Unlock()
+	if err != nil {
+		return "", err
+	}
+	return sealWith(c.key, password)
+}
+
+// open decrypts a sealed password. Keep the result only as long as it is needed.
+func (c *Competition) open(sealed string) (string, error) {
+	if sealed == "" {
+		return "", ErrPasswordMissing
+	}
+	err := c.Unlock()
+	if err != nil {
+		return "", err
+	}
+
+	password, err := openWith(c.key, sealed)
+	if err != nil {
+		return "", fmt.Errorf("could not open password: %w", err)
+	}
+	return password, nil
+}
+
+// HasPlaintextPasswords reports whether passwords saved by older versions are still in plaintext
+func (c *Competition) HasPlaintextPasswords() bool {
+	for _, r := range c.Rounds {
+		for _, g := range r.Groups {
+			if g.Password != "" {
+				return true
+			}
+			for _, a := range g.Attempts {
+				if a.Password != "" {
+					return true
+				}
+			}
+		}
+	}
+	return false
+}
+
+// sealPasswords seals the plaintext passwords saved by older versions
+func (c *Competition) sealPasswords() error {
+	for i, r := range c.Rounds {
+		for j, g := range r.Groups {
+			group := &c.Rounds[i].Groups[j]
+			if g.Password != "" {
+				sealed, err := c.seal(g.Password)
+				if err != nil {
+					return err
+				}
+				group.SealedPassword = sealed
+				group.Password = ""
+			}
+
+			for k, a := range g.Attempts {
+				if a.Password == "" {
+					continue
+				}
+				sealed, err := c.seal(a.Password)
+				if err != nil {
+					return err
+				}
+				group.Attempts[k].SealedPassword = sealed
+				group.Attempts[k].Password = ""
+			}
+		}
+	}
+	return nil
+}
+
+func sealWith(key []byte, plaintext string) (string, error) {
+	gcm, err := newGCM(key)
+	if err != nil {
+		return "", err
+	}
+
+	nonce := make([]byte, gcm.NonceSize())
+	_, err = rand.Read(nonce)
+	if err != nil {
+		return "", err
+	}
+	sealed := gcm.Seal(nonce, nonce, []byte(plaintext), nil)
+	return base64.StdEncoding.EncodeToString(sealed), nil
+}
+
+func openWith(key []byte, sealed string) (string, error) {
+	data, err := base64.StdEncoding.DecodeString(sealed)
+	if err != nil {
+		return "", err
+	}
+	gcm, err := newGCM(key)
+	if err != nil {
+		return "", err
+	}
+	if len(data) < gcm.NonceSize() {
+		return "", errors.New("sealed password too short")
+	}
+
+	nonce, ciphertext := data[:gcm.NonceSize()], data[gcm.NonceSize():]
+	plaintext, err := gcm.Open(nil, nonce, ciphertext, nil)
+	if err != nil {
+		return "", err
+	}
+	return string(plaintext), nil
+}
+
+func newGCM(key []byte) (cipher.AEAD, error) {
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, err
+	}
+	return cipher.NewGCM(block)
+}
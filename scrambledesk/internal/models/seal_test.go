@@ -0,0 +1,89 @@
+package models
+
+import (
+	"errors"
+	"testing"
+)
+
+// unlocked returns a competition whose passwords are sealed by passphrase
+func unlocked(t *testing.T, passphrase string) *Competition {
+	t.Helper()
+	Passphrase = func(bool) (string, error) { return passphrase, nil }
+	t.Cleanup(func() { Passphrase = nil })
+
+	c := &Competition{}
+	err := c.Unlock()
+	if err != nil {
+		t.Fatal(err)
+	}
+	return c
+}
+
+func TestSealOpen(t *testing.T) {
+	c := unlocked(t, "correct horse")
+	sealed, err := c.seal("1a2b3c")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if sealed == "1a2b3c" {
+		t.Fatal("seal() returned the password")
+	}
+
+	password, err := c.open(sealed)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if password != "1a2b3c" {
+		t.Errorf("open() = %q, want %q", password, "1a2b3c")
+	}
+}
+
+func TestUnlock(t *testing.T) {
+	sealing := unlocked(t, "correct horse").Sealing
+
+	tests := []struct {
+		name       string
+		passphrase string
+		err        error
+	}{
+		{"same passphrase", "correct horse", nil},
+		{"wrong passphrase", "battery staple", ErrWrongPassphrase},
+		{"different case", "Correct Horse", ErrWrongPassphrase},
+		{"no passphrase", "", ErrNoPassphrase},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			Passphrase = func(bool) (string, error) { return tt.passphrase, nil }
+			t.Cleanup(func() { Passphrase = nil })
+
+			c := &Competition{Sealing: sealing}
+			err := c.Unlock()
+			if !errors.Is(err, tt.err) {
+				t.Fatalf("Unlock() error = %v, want %v", err, tt.err)
+			}
+		})
+	}
+}
+
+func TestOpenWrongKey(t *testing.T) {
+	sealed, err := unlocked(t, "correct horse").seal("1a2b3c")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Another competition has its own salt, so the same passphrase gives another key
+	other := unlocked(t, "correct horse")
+	_, err = other.open(sealed)
+	if err == nil {
+		t.Fatal("open() with another key succeeded")
+	}
+
+	_, err = other.open("")
+	if !errors.Is(err, ErrPasswordMissing) {
+		t.Errorf("open(\"\") error = %v, want %v", err, ErrPasswordMissing)
+	}
+	_, err = other.open("not base64!")
+	if err == nil {
+		t.Error("open() of a damaged password succeeded")
+	}
+}
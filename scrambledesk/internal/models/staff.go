@@ -0,0 +1,75 @@
+package models
+
+const (
+	AssignmentCompetitor = "competitor"
+	AssignmentJudge      = "staff-judge"
+	AssignmentScrambler  = "staff-scrambler"
+	AssignmentRunner     = "staff-runner"
+	AssignmentDataEntry  = "staff-dataentry"
+)
+
+type StaffMember struct {
+	Person Person
+	Role   string
+}
+
+// StaffMembers lists everyone staffing the group, scramblers first. Staff
+// from the rotation fallback have no role.
+func (g *Group) StaffMembers() []StaffMember {
+	var staff []StaffMember
+	roles := []struct {
+		name    string
+		persons []Person
+	}{
+		{"Scrambler", g.Scramblers},
+		{"Runner", g.Runners},
+		{"Judge", g.Judges},
+		{"Data entry", g.DataEntry},
+		{"", g.Staff},
+	}
+	for _, role := range roles {
+		for _, p := range role.persons {
+			staff = append(staff, StaffMember{Person: p, Role: role.name})
+		}
+	}
+	return staff
+}
+
+func (g *Group) clearStaff() {
+	g.Staff = []Person{}
+	g.Judges = nil
+	g.Scramblers = nil
+	g.Runners = nil
+	g.DataEntry = nil
+}
+
+// assignPublishedStaff fills the staff roles of the round's groups from the WCIF
+// assignments. Returns false if the round has no staff assignments.
+func (c *Competition) assignPublishedStaff(r *Round) bool {
+	published := false
+	for j := range r.Groups {
+		group := &r.Groups[j]
+		for _, p := range c.Persons {
+			for _, assign := range p.Assignments {
+				if !group.hasActivity(assign.ActivityId) {
+					continue
+				}
+
+				switch assign.AssignmentCode {
+				case AssignmentJudge:
+					group.Judges = addPerson(group.Judges, p.simplified())
+				case AssignmentScrambler:
+					group.Scramblers = addPerson(group.Scramblers, p.simplified())
+				case AssignmentRunner:
+					group.Runners = addPerson(group.Runners, p.simplified())
+				case AssignmentDataEntry:
+					group.DataEntry = addPerson(group.DataEntry, p.simplified())
+				default:
+					continue
+				}
+				published = true
+			}
+		}
+	}
+	return published
+}
@@ -0,0 +1,82 @@
+package models
+
+import (
+	"sort"
+	"time"
+)
+
+// slot is a group, or one attempt of a group scrambled per attempt, as it is
+// scheduled. Attempts are scheduled on their own, often with other events in between.
+type slot struct {
+	round   *Round
+	group   *Group
+	attempt *Attempt
+}
+
+func (s slot) lifecycle() *Lifecycle {
+	if s.attempt != nil {
+		return &s.attempt.Lifecycle
+	}
+	return &s.group.Lifecycle
+}
+
+func (s slot) start() time.Time {
+	if s.attempt != nil && !s.attempt.StartTime.IsZero() {
+		return s.attempt.StartTime
+	}
+	if !s.group.StartTime.IsZero() {
+		return s.group.StartTime
+	}
+	return s.round.StartTime
+}
+
+func (s slot) end() time.Time {
+	if s.attempt != nil && !s.attempt.EndTime.IsZero() {
+		return s.attempt.EndTime
+	}
+	if !s.group.EndTime.IsZero() {
+		return s.group.EndTime
+	}
+	return s.round.EndTime
+}
+
+func (s slot) matches(activityCode string) bool {
+	return s.group.ActivityCode == activityCode || (s.attempt != nil && s.attempt.ActivityCode == activityCode)
+}
+
+// timeline lists the groups and attempts of the managed rooms in the order they are scheduled
+func (c *Competition) timeline() []slot {
+	var slots []slot
+	for i, r := range c.Rounds {
+		if !c.roomSelected(r) {
+			continue
+		}
+		round := &c.Rounds[i]
+		for j, g := range r.Groups {
+			group := &c.Rounds[i].Groups[j]
+			if len(g.Attempts) == 0 {
+				slots = append(slots, slot{round: round, group: group})
+				continue
+			}
+			for k := range g.Attempts {
+				slots = append(slots, slot{round: round, group: group, attempt: &group.Attempts[k]})
+			}
+		}
+	}
+
+	// Slots starting at the same time keep the order of the rounds and groups
+	sort.SliceStable(slots, func(i, j int) bool {
+		return slots[i].start().Before(slots[j].start())
+	})
+	return slots
+}
+
+// nextSlot returns the first pending group or attempt in the managed rooms
+func (c *Competition) nextSlot() (slot, bool) {
+	for _, s := range c.timeline() {
+		if s.lifecycle().state() == StatePending {
+			return s, true
+		}
+	}
+	return slot{}, false
+}
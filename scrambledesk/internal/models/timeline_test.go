@@ -0,0 +1,139 @@
+package models
+
+import (
+	"slices"
+	"testing"
+	"time"
+)
+
+// timelineCompetition has FMC scrambled per attempt with other events between
+// its attempts, and 2x2x2 in a second room at the same time as 3x3x3
+func timelineCompetition() *Competition {
+	at := func(hour int) time.Time { return time.Date(2026, 5, 2, hour, 0, 0, 0, time.UTC) }
+
+	fm := Round{EventId: "333fm", RoundNumber: 1, ActivityCode: "333fm-r1", RoomID: 1}
+	for n, hour := range []int{9, 13, 16} {
+		fm.Attempts = append(fm.Attempts, Attempt{Number: n + 1, StartTime: at(hour), EndTime: at(hour + 1)})
+	}
+	fm.Groups = []Group{fm.newGroup(1)}
+	fm.fillAttempts()
+
+	three := Round{EventId: "333", RoundNumber: 1, ActivityCode: "333-r1", RoomID: 1}
+	three.Groups = []Group{three.newGroup(1), three.newGroup(2)}
+	three.Groups[0].StartTime, three.Groups[0].EndTime = at(10), at(11)
+	three.Groups[1].StartTime, three.Groups[1].EndTime = at(11), at(12)
+
+	two := Round{EventId: "222", RoundNumber: 1, ActivityCode: "222-r1", RoomID: 2}
+	two.Groups = []Group{two.newGroup(1)}
+	two.Groups[0].StartTime, two.Groups[0].EndTime = at(10), at(11)
+
+	// Without times of its own the group is scheduled with its round
+	pyram := Round{EventId: "pyram", RoundNumber: 1, ActivityCode: "pyram-r1", RoomID: 1, StartTime: at(12), EndTime: at(13)}
+	pyram.Groups = []Group{{ActivityCode: "pyram-r1-g1", Lifecycle: Lifecycle{State: StatePending}}}
+
+	return &Competition{Rounds: []Round{fm, three, two, pyram}}
+}
+
+// slotCode returns the activity code of the attempt or group
+func slotCode(s slot) string {
+	if s.attempt != nil {
+		return s.attempt.ActivityCode
+	}
+	return s.group.ActivityCode
+}
+
+func slotCodes(slots []slot) []string {
+	var codes []string
+	for _, s := range slots {
+		codes = append(codes, slotCode(s))
+	}
+	return codes
+}
+
+func TestTimeline(t *testing.T) {
+	tests := []struct {
+		name  string
+		rooms []int
+		want  []string
+	}{
+		{"every room", nil, []string{
+			"333fm-r1-g1-a1", "333-r1-g1", "222-r1-g1", "333-r1-g2", "pyram-r1-g1", "333fm-r1-g1-a2", "333fm-r1-g1-a3",
+		}},
+		{"one room", []int{1}, []string{
+			"333fm-r1-g1-a1", "333-r1-g1", "333-r1-g2", "pyram-r1-g1", "333fm-r1-g1-a2", "333fm-r1-g1-a3",
+		}},
+		{"other room", []int{2}, []string{"222-r1-g1"}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c := timelineCompetition()
+			c.SelectedRooms = tt.rooms
+			got := slotCodes(c.timeline())
+			if !slices.Equal(got, tt.want) {
+				t.Errorf("timeline() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestNextSlot(t *testing.T) {
+	tests := []struct {
+		name string
+		// states moves the groups and attempts to a state before looking for the next
+		states map[string]State
+		want   string
+	}{
+		{"first attempt", nil, "333fm-r1-g1-a1"},
+		{"between attempts", map[string]State{"333fm-r1-g1-a1": StateClosed}, "333-r1-g1"},
+		{"active is not next", map[string]State{"333fm-r1-g1-a1": StateScramblesOpen}, "333-r1-g1"},
+		{"skipped is not next", map[string]State{"333fm-r1-g1-a1": StateClosed, "333-r1-g1": StateSkipped}, "222-r1-g1"},
+		{"second attempt", map[string]State{
+			"333fm-r1-g1-a1": StateClosed, "333-r1-g1": StateClosed, "222-r1-g1": StateClosed,
+			"333-r1-g2": StateClosed, "pyram-r1-g1": StateClosed,
+		}, "333fm-r1-g1-a2"},
+		{"all done", map[string]State{
+			"333fm-r1-g1-a1": StateClosed, "333-r1-g1": StateClosed, "222-r1-g1": StateClosed,
+			"333-r1-g2": StateClosed, "pyram-r1-g1": StateClosed,
+			"333fm-r1-g1-a2": StateClosed, "333fm-r1-g1-a3": StateSkipped,
+		}, ""},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c := timelineCompetition()
+			for _, s := range c.timeline() {
+				if state, ok := tt.states[slotCode(s)]; ok {
+					s.lifecycle().State = state
+				}
+			}
+
+			s, ok := c.nextSlot()
+			if ok != (tt.want != "") {
+				t.Fatalf("nextSlot() found %t, want %t", ok, tt.want != "")
+			}
+			if ok && slotCode(s) != tt.want {
+				t.Errorf("nextSlot() = %s, want %s", slotCode(s), tt.want)
+			}
+		})
+	}
+}
+
+func TestFillAttempts(t *testing.T) {
+	c := timelineCompetition()
+	g := c.Rounds[0].Groups[0]
+
+	var numbers []int
+	for _, a := range g.Attempts {
+		numbers = append(numbers, a.Number)
+		if a.state() != StatePending {
+			t.Errorf("attempt %d is %s, want %s", a.Number, a.state(), StatePending)
+		}
+	}
+	if !slices.Equal(numbers, []int{1, 2, 3}) {
+		t.Errorf("attempts = %v, want [1 2 3]", numbers)
+	}
+	if !g.StartTime.Equal(g.Attempts[0].StartTime) || !g.EndTime.Equal(g.Attempts[2].EndTime) {
+		t.Errorf("group spans %v to %v, want the first attempt's start to the last attempt's end", g.StartTime, g.EndTime)
+	}
+}
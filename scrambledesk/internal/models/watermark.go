@@ -0,0 +1,43 @@
+package models
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"fmt"
+	"time"
+)
+
+// DefaultWatermarkStyle is the pdfcpu description of the watermark, small and
+// grey in the bottom left corner where it does not cover the scrambles
+const DefaultWatermarkStyle = "fontname:Helvetica, points:18, position:bl, offset:20 20, scalefactor:1 abs, rotation:0, opacity:0.6, fillcolor:#808080"
+
+// sessionID tells the runs of the desk apart, so a photo of a watermarked
+// scramble set can be traced back to the session that sent it
+var sessionID = newSessionID()
+
+// Watermark configures the text stamped on every page of the decrypted scramble sets
+type Watermark struct {
+	Disabled bool `json:",omitempty"`
+	// Style is a pdfcpu watermark description, DefaultWatermarkStyle when empty
+	Style string `json:",omitempty"`
+}
+
+func (w Watermark) style() string {
+	if w.Style == "" {
+		return DefaultWatermarkStyle
+	}
+	return w.Style
+}
+
+func watermarkText(activity string, opened time.Time) string {
+	return fmt.Sprintf("%s  %s  %s", activity, opened.Local().Format("2006-01-02 15:04:05"), sessionID)
+}
+
+func newSessionID() string {
+	b := make([]byte, 3)
+	_, err := rand.Read(b)
+	if err != nil {
+		return "unknown"
+	}
+	return hex.EncodeToString(b)
+}
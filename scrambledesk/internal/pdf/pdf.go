@@ -7,17 +7,40 @@ import (
 
 	"github.com/pdfcpu/pdfcpu/pkg/api"
 	"github.com/pdfcpu/pdfcpu/pkg/pdfcpu/model"
+	"github.com/pdfcpu/pdfcpu/pkg/pdfcpu/types"
 )
 
 func DecryptPDF(inputPath, password string) error {
-	fmt.Println(inputPath)
+	return DecryptPDFTo(inputPath, path.Join(config.AppDataDir, "active.pdf"), password)
+}
+
+// DecryptPDFTo decrypts the PDF into outputPath
+func DecryptPDFTo(inputPath, outputPath, password string) error {
 	conf := model.NewAESConfiguration(password, "", 256)
 
 	// Perform decryption.
-	outputPath := path.Join(config.AppDataDir, "active.pdf")
 	err := api.DecryptFile(inputPath, outputPath, conf)
 	if err != nil {
 		return fmt.Errorf("decrypt failed: %w", err)
 	}
 	return nil
 }
+
+// Watermark stamps the text on every page of the PDF, in place. The
+// description sets the font, position and colour, as described by pdfcpu.
+func Watermark(path, text, description string) error {
+	err := api.AddTextWatermarksFile(path, "", nil, true, text, description, nil)
+	if err != nil {
+		return fmt.Errorf("watermark failed: %w", err)
+	}
+	return nil
+}
+
+// ValidateWatermark checks that pdfcpu understands the watermark description
+func ValidateWatermark(description string) error {
+	_, err := api.TextWatermark("check", description, true, false, types.POINTS)
+	if err != nil {
+		return fmt.Errorf("invalid watermark style: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,19 @@
+// Package protocol defines the wire contract between the scramble desk and the display.
+package protocol
+
+const (
+	// Port the display listens on for uploads over mutual TLS
+	Port = 2013
+
+	// ScramblePath receives the decrypted scramble set (or the intermission screen)
+	ScramblePath = "/upload"
+
+	// GroupPath receives the competitor and staff overview of the current group
+	GroupPath = "/group"
+
+	// FormField is the multipart field carrying the PDF
+	FormField = "file"
+
+	// MaxUploadSize is the largest PDF the display accepts
+	MaxUploadSize = 32 << 20
+)
@@ -0,0 +1,114 @@
+package tnoodle
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"slices"
+	"strconv"
+	"strings"
+)
+
+// perAttemptEvents are scrambled per attempt, TNoodle makes a PDF for every scramble of their sets
+var perAttemptEvents = []string{"333fm", "333mbf"}
+
+// Interchange is the JSON TNoodle writes alongside the scramble sets
+type Interchange struct {
+	Path string
+	// Keys of every scramble set PDF the JSON describes
+	Keys []Key
+}
+
+type interchangeSet struct {
+	Scrambles []json.RawMessage `json:"scrambles"`
+}
+
+type interchangeWCIF struct {
+	Events []struct {
+		ID     string `json:"id"`
+		Rounds []struct {
+			ID           string           `json:"id"`
+			ScrambleSets []interchangeSet `json:"scrambleSets"`
+		} `json:"rounds"`
+	} `json:"events"`
+}
+
+// ReadInterchange reads the JSON generated by TNoodle. Both the WCIF based
+// format and the older format listing sheets are understood.
+func ReadInterchange(path string) (*Interchange, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	var raw struct {
+		interchangeWCIF
+		WCIF   *interchangeWCIF `json:"wcif"`
+		Sheets []struct {
+			Event string `json:"event"`
+			Round int    `json:"round"`
+			Group string `json:"group"`
+			interchangeSet
+		} `json:"sheets"`
+	}
+	err = json.Unmarshal(data, &raw)
+	if err != nil {
+		return nil, fmt.Errorf("could not read %s: %w", path, err)
+	}
+
+	wcif := raw.interchangeWCIF
+	if raw.WCIF != nil {
+		wcif = *raw.WCIF
+	}
+
+	ix := &Interchange{Path: path}
+	for _, e := range wcif.Events {
+		for _, r := range e.Rounds {
+			_, number, _ := strings.Cut(r.ID, "-r")
+			round, err := strconv.Atoi(number)
+			if err != nil {
+				return nil, fmt.Errorf("could not read %s: invalid round %q", path, r.ID)
+			}
+			for i, set := range r.ScrambleSets {
+				ix.add(Key{EventID: e.ID, Round: round, Set: i + 1}, len(set.Scrambles))
+			}
+		}
+	}
+
+	for _, sheet := range raw.Sheets {
+		set, ok := SetNumber(sheet.Group)
+		if !ok {
+			return nil, fmt.Errorf("could not read %s: invalid scramble set %q", path, sheet.Group)
+		}
+		ix.add(Key{EventID: sheet.Event, Round: sheet.Round, Set: set}, len(sheet.Scrambles))
+	}
+	return ix, nil
+}
+
+func (ix *Interchange) add(key Key, scrambles int) {
+	if !slices.Contains(perAttemptEvents, key.EventID) {
+		ix.Keys = append(ix.Keys, key)
+		return
+	}
+	for attempt := 1; attempt <= scrambles; attempt++ {
+		key.Attempt = attempt
+		ix.Keys = append(ix.Keys, key)
+	}
+}
+
+// Sets returns the set numbers of a round, in order
+func (ix *Interchange) Sets(eventID string, round int) []int {
+	var numbers []int
+	for _, key := range ix.Keys {
+		if key.EventID == eventID && key.Round == round && !slices.Contains(numbers, key.Set) {
+			numbers = append(numbers, key.Set)
+		}
+	}
+	slices.Sort(numbers)
+	return numbers
+}
+
+// Contains reports whether the JSON describes the scramble set
+func (ix *Interchange) Contains(key Key) bool {
+	return slices.Contains(ix.Keys, key)
+}
@@ -0,0 +1,97 @@
+package tnoodle
+
+import (
+	"bytes"
+	"os"
+	"strings"
+	"unicode/utf16"
+)
+
+// Passcode is an entry of the passcode file TNoodle writes for the computer display PDFs
+type Passcode struct {
+	Key
+	Name     string
+	Password string
+	Line     int
+}
+
+// passcodeSeparators are tried in order, the set name never contains any of them
+var passcodeSeparators = []string{":", "\t", "=", " - "}
+
+// ReadPasscodes reads the passcode file
+func ReadPasscodes(path string) ([]Passcode, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	return ParsePasscodes(data), nil
+}
+
+// ParsePasscodes parses the passcodes of every TNoodle version, in UTF-8 or
+// UTF-16 with or without a byte order mark. Lines that are not a scramble set
+// followed by its passcode, like the header of the file, are skipped.
+func ParsePasscodes(data []byte) []Passcode {
+	var passcodes []Passcode
+	text := strings.NewReplacer("\r\n", "\n", "\r", "\n").Replace(decodeText(data))
+	for i, line := range strings.Split(text, "\n") {
+		name, password, ok := splitPasscode(line)
+		if !ok {
+			continue
+		}
+
+		key, err := ParseName(name)
+		if err != nil {
+			continue
+		}
+		passcodes = append(passcodes, Passcode{Key: key, Name: name, Password: password, Line: i + 1})
+	}
+	return passcodes
+}
+
+func splitPasscode(line string) (string, string, bool) {
+	line = strings.TrimSpace(strings.TrimPrefix(line, "\ufeff"))
+	for _, sep := range passcodeSeparators {
+		name, password, ok := strings.Cut(line, sep)
+		if !ok {
+			continue
+		}
+		name = strings.TrimSpace(name)
+		password = strings.Trim(strings.TrimSpace(password), `"'`)
+		if name == "" || password == "" || strings.ContainsAny(password, " \t") {
+			continue
+		}
+		return name, password, true
+	}
+	return "", "", false
+}
+
+// decodeText converts UTF-16 to UTF-8 and drops a UTF-8 byte order mark
+func decodeText(data []byte) string {
+	switch {
+	case bytes.HasPrefix(data, []byte{0xEF, 0xBB, 0xBF}):
+		return string(data[3:])
+	case bytes.HasPrefix(data, []byte{0xFF, 0xFE}):
+		return decodeUTF16(data[2:], false)
+	case bytes.HasPrefix(data, []byte{0xFE, 0xFF}):
+		return decodeUTF16(data[2:], true)
+	}
+
+	// UTF-16 without a byte order mark has a zero byte in every ASCII character
+	if bytes.Count(data, []byte{0}) > len(data)/4 {
+		bigEndian := len(data) > 1 && data[0] == 0
+		return decodeUTF16(data, bigEndian)
+	}
+	return string(data)
+}
+
+func decodeUTF16(data []byte, bigEndian bool) string {
+	units := make([]uint16, len(data)/2)
+	for i := range units {
+		if bigEndian {
+			units[i] = uint16(data[2*i])<<8 | uint16(data[2*i+1])
+		} else {
+			units[i] = uint16(data[2*i+1])<<8 | uint16(data[2*i])
+		}
+	}
+	return string(utf16.Decode(units))
+}
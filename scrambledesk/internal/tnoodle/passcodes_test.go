@@ -0,0 +1,71 @@
+package tnoodle
+
+import (
+	"encoding/binary"
+	"testing"
+	"unicode/utf16"
+)
+
+const passcodeText = "Passcodes for the computer display PDFs\r\n" +
+	"\r\n" +
+	"3x3x3 Round 1 Scramble Set A: 1a2b3c\r\n" +
+	"3x3x3 Fewest Moves Round 1 Scramble Set A Attempt 2: 4d5e6f\r\n"
+
+var wantPasscodes = []Passcode{
+	{Key: Key{EventID: "333", Round: 1, Set: 1}, Name: "3x3x3 Round 1 Scramble Set A", Password: "1a2b3c", Line: 3},
+	{Key: Key{EventID: "333fm", Round: 1, Set: 1, Attempt: 2}, Name: "3x3x3 Fewest Moves Round 1 Scramble Set A Attempt 2", Password: "4d5e6f", Line: 4},
+}
+
+func encodeUTF16(text string, order binary.AppendByteOrder, bom bool) []byte {
+	var data []byte
+	units := utf16.Encode([]rune(text))
+	if bom {
+		units = append([]uint16{0xFEFF}, units...)
+	}
+	for _, u := range units {
+		data = order.AppendUint16(data, u)
+	}
+	return data
+}
+
+func TestParsePasscodes(t *testing.T) {
+	tests := []struct {
+		name string
+		data []byte
+		want []Passcode
+	}{
+		{"UTF-8", []byte(passcodeText), wantPasscodes},
+		{"UTF-8 with BOM", append([]byte{0xEF, 0xBB, 0xBF}, passcodeText...), wantPasscodes},
+		{"UTF-16LE with BOM", encodeUTF16(passcodeText, binary.LittleEndian, true), wantPasscodes},
+		{"UTF-16BE with BOM", encodeUTF16(passcodeText, binary.BigEndian, true), wantPasscodes},
+		{"UTF-16LE without BOM", encodeUTF16(passcodeText, binary.LittleEndian, false), wantPasscodes},
+		{"UTF-16BE without BOM", encodeUTF16(passcodeText, binary.BigEndian, false), wantPasscodes},
+		{"LF line endings", []byte("3x3x3 Round 1 Scramble Set A: 1a2b3c\n"), []Passcode{
+			{Key: Key{EventID: "333", Round: 1, Set: 1}, Name: "3x3x3 Round 1 Scramble Set A", Password: "1a2b3c", Line: 1},
+		}},
+		{"tab separated", []byte("3x3x3 Round 1 Scramble Set A\t1a2b3c\n"), []Passcode{
+			{Key: Key{EventID: "333", Round: 1, Set: 1}, Name: "3x3x3 Round 1 Scramble Set A", Password: "1a2b3c", Line: 1},
+		}},
+		{"quoted passcode", []byte(`3x3x3 Round 1 Scramble Set A = "1a2b3c"`), []Passcode{
+			{Key: Key{EventID: "333", Round: 1, Set: 1}, Name: "3x3x3 Round 1 Scramble Set A", Password: "1a2b3c", Line: 1},
+		}},
+		{"dash separated, older names", []byte("Megaminx, Round 2, Group B - 7g8h9i\n"), []Passcode{
+			{Key: Key{EventID: "minx", Round: 2, Set: 2}, Name: "Megaminx, Round 2, Group B", Password: "7g8h9i", Line: 1},
+		}},
+		{"only a header", []byte("Passcodes: none yet\n"), nil},
+		{"empty", nil, nil},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := ParsePasscodes(tt.data)
+			if len(got) != len(tt.want) {
+				t.Fatalf("ParsePasscodes() = %+v, want %+v", got, tt.want)
+			}
+			for i := range got {
+				if got[i] != tt.want[i] {
+					t.Errorf("passcode %d = %+v, want %+v", i, got[i], tt.want[i])
+				}
+			}
+		})
+	}
+}
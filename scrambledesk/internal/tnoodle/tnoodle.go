@@ -0,0 +1,270 @@
+// Package tnoodle reads the scramble set files generated by TNoodle.
+package tnoodle
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"slices"
+	"strconv"
+	"strings"
+)
+
+var (
+	ErrUnknownEvent = errors.New("unknown event")
+	ErrInvalidName  = errors.New("not a scramble set file name")
+	ErrMissing      = errors.New("scramble set missing")
+	ErrAmbiguous    = errors.New("scramble set ambiguous")
+)
+
+// EventNames maps WCA event IDs to the names TNoodle uses in its file names
+var EventNames = map[string]string{
+	"222":    "2x2x2",
+	"333":    "3x3x3",
+	"444":    "4x4x4",
+	"555":    "5x5x5",
+	"666":    "6x6x6",
+	"777":    "7x7x7",
+	"333bf":  "3x3x3 Blindfolded",
+	"333fm":  "3x3x3 Fewest Moves",
+	"333oh":  "3x3x3 One-Handed",
+	"clock":  "Clock",
+	"minx":   "Megaminx",
+	"pyram":  "Pyraminx",
+	"skewb":  "Skewb",
+	"sq1":    "Square-1",
+	"444bf":  "4x4x4 Blindfolded",
+	"555bf":  "5x5x5 Blindfolded",
+	"333mbf": "3x3x3 Multiple Blindfolded",
+}
+
+// eventAliases are names other TNoodle versions used for events, normalised by normaliseEvent
+var eventAliases = map[string]string{
+	"3x3x3 multi blind": "333mbf",
+	"3x3x3 oh":          "333oh",
+	"3x3x3 bld":         "333bf",
+}
+
+// eventWords are spellings of the same word in event names
+var eventWords = map[string]string{
+	"handed":      "hand",
+	"blindfolded": "blind",
+	"moves":       "move",
+}
+
+// <Event> Round <N> Scramble Set <Letters>[ Attempt <N>]. Older TNoodle
+// versions say Group instead of Scramble Set and separate the parts with commas.
+var setPattern = regexp.MustCompile(`(?i)^(.+?)[ ,]+Round (\d+)[ ,]+(?:Scramble Set|Group|Set) ([A-Z]+)(?:[ ,]+Attempt (\d+))?$`)
+
+// Key identifies a scramble set. Attempt is 0 for events scrambled per round
+// rather than per attempt.
+type Key struct {
+	EventID string
+	Round   int
+	Set     int
+	Attempt int
+}
+
+func (k Key) String() string {
+	return SetName(k.EventID, k.Round, k.Set, k.Attempt)
+}
+
+// Set is a scramble set file
+type Set struct {
+	Key
+	Name string
+	Path string
+}
+
+// RoundName is how TNoodle names a round, like "3x3x3 One-Handed Round 1"
+func RoundName(eventID string, round int) string {
+	event, ok := EventNames[eventID]
+	if !ok {
+		event = eventID
+	}
+	return fmt.Sprintf("%s Round %d", event, round)
+}
+
+// SetName is the name TNoodle gives the scramble set, without the extension
+func SetName(eventID string, round, set, attempt int) string {
+	name := fmt.Sprintf("%s Scramble Set %s", RoundName(eventID, round), SetLetters(set))
+	if attempt > 0 {
+		name += fmt.Sprintf(" Attempt %d", attempt)
+	}
+	return name
+}
+
+// SetLetters converts a set number to letters, 1 is A, 26 is Z and 27 is AA
+func SetLetters(set int) string {
+	var letters []byte
+	for set > 0 {
+		set--
+		letters = append(letters, byte('A'+set%26))
+		set /= 26
+	}
+	slices.Reverse(letters)
+	return string(letters)
+}
+
+// SetNumber converts set letters back to a number, the inverse of SetLetters
+func SetNumber(letters string) (int, bool) {
+	if letters == "" {
+		return 0, false
+	}
+	n := 0
+	for _, l := range letters {
+		if l < 'A' || l > 'Z' {
+			return 0, false
+		}
+		n = n*26 + int(l-'A') + 1
+	}
+	return n, true
+}
+
+// ParseName parses a scramble set name, with or without the .pdf extension
+func ParseName(name string) (Key, error) {
+	base := name
+	if strings.EqualFold(filepath.Ext(base), ".pdf") {
+		base = strings.TrimSuffix(base, filepath.Ext(base))
+	}
+
+	m := setPattern.FindStringSubmatch(strings.Join(strings.Fields(base), " "))
+	if m == nil {
+		return Key{}, fmt.Errorf("%w: %s", ErrInvalidName, name)
+	}
+
+	eventID, ok := eventID(m[1])
+	if !ok {
+		return Key{}, fmt.Errorf("%w %q in %s", ErrUnknownEvent, m[1], name)
+	}
+
+	key := Key{EventID: eventID}
+	key.Round, _ = strconv.Atoi(m[2])
+	key.Set, _ = SetNumber(strings.ToUpper(m[3]))
+	if m[4] != "" {
+		key.Attempt, _ = strconv.Atoi(m[4])
+	}
+	return key, nil
+}
+
+// eventID finds the event by its TNoodle name, an older name or its WCA ID
+func eventID(name string) (string, bool) {
+	if _, ok := EventNames[name]; ok {
+		return name, true
+	}
+
+	name = normaliseEvent(name)
+	for id, n := range EventNames {
+		if normaliseEvent(n) == name {
+			return id, true
+		}
+	}
+	id, ok := eventAliases[name]
+	return id, ok
+}
+
+// normaliseEvent lowercases the event name and drops the punctuation and words
+// that differ between TNoodle versions, "3x3x3 Cube One-Handed" becomes "3x3x3 one hand"
+func normaliseEvent(name string) string {
+	name = strings.ToLower(name)
+	name = strings.NewReplacer("-", " ", "_", " ", "'", "").Replace(name)
+
+	var words []string
+	for _, w := range strings.Fields(name) {
+		if w == "cube" || w == "rubiks" {
+			continue
+		}
+		if replacement, ok := eventWords[w]; ok {
+			w = replacement
+		}
+		words = append(words, w)
+	}
+	return strings.Join(words, " ")
+}
+
+// Index is the scramble sets found in a directory
+type Index struct {
+	Dir  string
+	sets map[Key][]Set
+	// Problems found while scanning, such as files that are not scramble sets
+	Problems []error
+}
+
+// ScanDir indexes the scramble set PDFs in the directory
+func ScanDir(dir string) (*Index, error) {
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return nil, fmt.Errorf("could not read scramble sets: %w", err)
+	}
+
+	index := &Index{Dir: dir, sets: make(map[Key][]Set)}
+	for _, e := range entries {
+		if e.IsDir() || !strings.EqualFold(filepath.Ext(e.Name()), ".pdf") {
+			continue
+		}
+
+		key, err := ParseName(e.Name())
+		if err != nil {
+			index.Problems = append(index.Problems, err)
+			continue
+		}
+
+		index.sets[key] = append(index.sets[key], Set{
+			Key:  key,
+			Name: strings.TrimSuffix(e.Name(), filepath.Ext(e.Name())),
+			Path: filepath.Join(dir, e.Name()),
+		})
+	}
+
+	for key, sets := range index.sets {
+		if len(sets) > 1 {
+			index.Problems = append(index.Problems, ambiguous(key, sets))
+		}
+	}
+	return index, nil
+}
+
+// Lookup finds the file of a scramble set
+func (ix *Index) Lookup(key Key) (Set, error) {
+	sets := ix.sets[key]
+	switch len(sets) {
+	case 0:
+		return Set{}, fmt.Errorf("%w: %s.pdf not found in %s", ErrMissing, key, ix.Dir)
+	case 1:
+		return sets[0], nil
+	default:
+		return Set{}, ambiguous(key, sets)
+	}
+}
+
+// Sets returns the set numbers of a round, in order
+func (ix *Index) Sets(eventID string, round int) []int {
+	var numbers []int
+	for key := range ix.sets {
+		if key.EventID == eventID && key.Round == round && !slices.Contains(numbers, key.Set) {
+			numbers = append(numbers, key.Set)
+		}
+	}
+	slices.Sort(numbers)
+	return numbers
+}
+
+// Keys returns the keys of every scramble set found, in order
+func (ix *Index) Keys() []Key {
+	var keys []Key
+	for key := range ix.sets {
+		keys = append(keys, key)
+	}
+	slices.SortFunc(keys, func(a, b Key) int { return strings.Compare(a.String(), b.String()) })
+	return keys
+}
+
+func ambiguous(key Key, sets []Set) error {
+	var names []string
+	for _, s := range sets {
+		names = append(names, filepath.Base(s.Path))
+	}
+	return fmt.Errorf("%w: %s matches %s", ErrAmbiguous, key, strings.Join(names, ", "))
+}
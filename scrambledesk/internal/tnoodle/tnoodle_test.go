@@ -0,0 +1,76 @@
+package tnoodle
+
+import (
+	"errors"
+	"testing"
+)
+
+func TestParseName(t *testing.T) {
+	tests := []struct {
+		name string
+		want Key
+		err  error
+	}{
+		{"3x3x3 Round 1 Scramble Set A.pdf", Key{EventID: "333", Round: 1, Set: 1}, nil},
+		{"3x3x3 Round 1 Scramble Set A", Key{EventID: "333", Round: 1, Set: 1}, nil},
+		{"3x3x3 Round 2 Scramble Set AA.PDF", Key{EventID: "333", Round: 2, Set: 27}, nil},
+		{"3x3x3 One-Handed Round 1 Scramble Set B.pdf", Key{EventID: "333oh", Round: 1, Set: 2}, nil},
+		{"3x3x3 Fewest Moves Round 1 Scramble Set A Attempt 2.pdf", Key{EventID: "333fm", Round: 1, Set: 1, Attempt: 2}, nil},
+		{"3x3x3 Multiple Blindfolded Round 1 Scramble Set A Attempt 1.pdf", Key{EventID: "333mbf", Round: 1, Set: 1, Attempt: 1}, nil},
+		// Older versions say Group and separate the parts with commas
+		{"3x3x3 Round 1 Group A.pdf", Key{EventID: "333", Round: 1, Set: 1}, nil},
+		{"Megaminx, Round 3, Group C.pdf", Key{EventID: "minx", Round: 3, Set: 3}, nil},
+		{"3x3x3 Round 1 Set A.pdf", Key{EventID: "333", Round: 1, Set: 1}, nil},
+		{"3x3x3  Round 1   Scramble Set A.pdf", Key{EventID: "333", Round: 1, Set: 1}, nil},
+		{"3x3x3 round 1 scramble set a.pdf", Key{EventID: "333", Round: 1, Set: 1}, nil},
+		// Event names of other versions
+		{"3x3x3 Cube One Handed Round 1 Scramble Set A.pdf", Key{EventID: "333oh", Round: 1, Set: 1}, nil},
+		{"3x3x3 Blindfolded Round 1 Scramble Set A.pdf", Key{EventID: "333bf", Round: 1, Set: 1}, nil},
+		{"3x3x3 Blind Round 1 Scramble Set A.pdf", Key{EventID: "333bf", Round: 1, Set: 1}, nil},
+		{"3x3x3 BLD Round 1 Scramble Set A.pdf", Key{EventID: "333bf", Round: 1, Set: 1}, nil},
+		{"3x3x3 OH Round 1 Scramble Set A.pdf", Key{EventID: "333oh", Round: 1, Set: 1}, nil},
+		{"3x3x3 Fewest Move Round 1 Scramble Set A Attempt 3.pdf", Key{EventID: "333fm", Round: 1, Set: 1, Attempt: 3}, nil},
+		{"3x3x3 Multi Blind Round 1 Scramble Set A Attempt 1.pdf", Key{EventID: "333mbf", Round: 1, Set: 1, Attempt: 1}, nil},
+		{"Square-1 Round 1 Scramble Set A.pdf", Key{EventID: "sq1", Round: 1, Set: 1}, nil},
+		{"333oh Round 1 Scramble Set A.pdf", Key{EventID: "333oh", Round: 1, Set: 1}, nil},
+		{"Feet Round 1 Scramble Set A.pdf", Key{}, ErrUnknownEvent},
+		{"Passcodes - SECRET.txt", Key{}, ErrInvalidName},
+		{"3x3x3 Round 1.pdf", Key{}, ErrInvalidName},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := ParseName(tt.name)
+			if tt.err != nil {
+				if !errors.Is(err, tt.err) {
+					t.Fatalf("ParseName(%q) error = %v, want %v", tt.name, err, tt.err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("ParseName(%q) error = %v", tt.name, err)
+			}
+			if got != tt.want {
+				t.Errorf("ParseName(%q) = %+v, want %+v", tt.name, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestSetNameRoundTrip(t *testing.T) {
+	for id := range EventNames {
+		for _, key := range []Key{
+			{EventID: id, Round: 1, Set: 1},
+			{EventID: id, Round: 4, Set: 28},
+			{EventID: id, Round: 1, Set: 2, Attempt: 3},
+		} {
+			got, err := ParseName(key.String() + ".pdf")
+			if err != nil {
+				t.Errorf("ParseName(%q) error = %v", key.String(), err)
+				continue
+			}
+			if got != key {
+				t.Errorf("ParseName(%q) = %+v, want %+v", key.String(), got, key)
+			}
+		}
+	}
+}
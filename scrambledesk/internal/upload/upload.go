@@ -0,0 +1,232 @@
+package upload
+
+import (
+	"bytes"
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
+	"fmt"
+	"io"
+	"mime/multipart"
+	"net"
+	"net/http"
+	"net/textproto"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/larsjohanfolde/scrambleman/scrambledesk/config"
+	"github.com/larsjohanfolde/scrambleman/scrambledesk/internal/protocol"
+)
+
+// Timeout is the default deadline for a single upload, including the TLS handshake
+const Timeout = 30 * time.Second
+
+// maxMessageSize caps how much of an error response we keep for the error message
+const maxMessageSize = 4096
+
+var ErrCertificates = errors.New("could not load certificates")
+
+// StatusError is returned when the display answers with anything but 200 OK
+type StatusError struct {
+	URL        string
+	StatusCode int
+	Message    string
+}
+
+func (e *StatusError) Error() string {
+	if e.Message == "" {
+		return fmt.Sprintf("upload to %s failed: %d %s", e.URL, e.StatusCode, http.StatusText(e.StatusCode))
+	}
+	return fmt.Sprintf("upload to %s failed: %d %s: %s", e.URL, e.StatusCode, http.StatusText(e.StatusCode), e.Message)
+}
+
+// RequestError is returned when the request never got a response, e.g. on
+// timeouts, refused connections or failed TLS handshakes
+type RequestError struct {
+	URL string
+	Err error
+}
+
+func (e *RequestError) Error() string {
+	return fmt.Sprintf("upload to %s failed: %v", e.URL, e.Err)
+}
+
+func (e *RequestError) Unwrap() error {
+	return e.Err
+}
+
+type Client struct {
+	http *http.Client
+}
+
+// NewClient creates a client authenticating with the given client certificate
+// and trusting only servers signed by the given CA
+func NewClient(certFile, keyFile, caFile string, timeout time.Duration) (*Client, error) {
+	tlsConfig, err := TLSConfig(certFile, keyFile, caFile)
+	if err != nil {
+		return nil, err
+	}
+
+	return &Client{
+		http: &http.Client{
+			Timeout: timeout,
+			Transport: &http.Transport{
+				TLSClientConfig:     tlsConfig,
+				TLSHandshakeTimeout: timeout,
+			},
+		},
+	}, nil
+}
+
+// NewDefaultClient creates a client from the certificates in the app data directory
+func NewDefaultClient() (*Client, error) {
+	return NewClient(config.ClientCrt, config.ClientKey, config.CaCrt, Timeout)
+}
+
+func TLSConfig(certFile, keyFile, caFile string) (*tls.Config, error) {
+	cert, err := tls.LoadX509KeyPair(certFile, keyFile)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrCertificates, err)
+	}
+
+	caPEM, err := os.ReadFile(caFile)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrCertificates, err)
+	}
+
+	pool := x509.NewCertPool()
+	if !pool.AppendCertsFromPEM(caPEM) {
+		return nil, fmt.Errorf("%w: no certificates found in %s", ErrCertificates, caFile)
+	}
+
+	return &tls.Config{
+		Certificates: []tls.Certificate{cert},
+		RootCAs:      pool,
+		MinVersion:   tls.VersionTLS12,
+	}, nil
+}
+
+// Upload sends the file as a multipart form to the given URL
+func (c *Client) Upload(url, file string) error {
+	body, contentType, err := multipartBody(file)
+	if err != nil {
+		return err
+	}
+
+	req, err := http.NewRequest(http.MethodPost, url, body)
+	if err != nil {
+		return &RequestError{URL: url, Err: err}
+	}
+	req.Header.Set("Content-Type", contentType)
+
+	resp, err := c.http.Do(req)
+	if err != nil {
+		return &RequestError{URL: url, Err: err}
+	}
+	defer resp.Body.Close()
+
+	message, _ := io.ReadAll(io.LimitReader(resp.Body, maxMessageSize))
+	if resp.StatusCode != http.StatusOK {
+		return &StatusError{
+			URL:        url,
+			StatusCode: resp.StatusCode,
+			Message:    strings.TrimSpace(string(message)),
+		}
+	}
+	return nil
+}
+
+// Ping performs a TLS handshake with the display to check that it is reachable
+// and accepts our client certificate
+func Ping(host string, timeout time.Duration) error {
+	tlsConfig, err := TLSConfig(config.ClientCrt, config.ClientKey, config.CaCrt)
+	if err != nil {
+		return err
+	}
+
+	addr := net.JoinHostPort(host, strconv.Itoa(protocol.Port))
+	dialer := &net.Dialer{Timeout: timeout}
+	conn, err := tls.DialWithDialer(dialer, "tcp", addr, tlsConfig)
+	if err != nil {
+		return err
+	}
+	defer conn.Close()
+
+	// With TLS 1.3 a rejected client certificate is only reported after the
+	// handshake, so wait briefly for an alert. A healthy server stays silent.
+	conn.SetReadDeadline(time.Now().Add(300 * time.Millisecond))
+	_, err = conn.Read(make([]byte, 1))
+	var netErr net.Error
+	if errors.As(err, &netErr) && netErr.Timeout() {
+		return nil
+	}
+	if err == nil {
+		return errors.New("unexpected data from display")
+	}
+	return err
+}
+
+var (
+	defaultMu     sync.Mutex
+	defaultClient *Client
+)
+
+// Send uploads a file to the display using the default client. The client is
+// built on the first successful call and reused, so its connections are too.
+func Send(file, url string) error {
+	client, err := sharedClient()
+	if err != nil {
+		return err
+	}
+	return client.Upload(url, file)
+}
+
+// sharedClient returns the default client, building it if the certificates
+// could not be loaded before
+func sharedClient() (*Client, error) {
+	defaultMu.Lock()
+	defer defaultMu.Unlock()
+
+	if defaultClient == nil {
+		client, err := NewDefaultClient()
+		if err != nil {
+			return nil, err
+		}
+		defaultClient = client
+	}
+	return defaultClient, nil
+}
+
+func multipartBody(file string) (*bytes.Buffer, string, error) {
+	data, err := os.ReadFile(file)
+	if err != nil {
+		return nil, "", err
+	}
+
+	body := &bytes.Buffer{}
+	writer := multipart.NewWriter(body)
+
+	header := make(textproto.MIMEHeader)
+	header.Set("Content-Disposition", fmt.Sprintf(`form-data; name="%s"; filename="%s"`, protocol.FormField, filepath.Base(file)))
+	header.Set("Content-Type", "application/pdf")
+
+	part, err := writer.CreatePart(header)
+	if err != nil {
+		return nil, "", err
+	}
+
+	_, err = part.Write(data)
+	if err != nil {
+		return nil, "", err
+	}
+
+	err = writer.Close()
+	if err != nil {
+		return nil, "", err
+	}
+	return body, writer.FormDataContentType(), nil
+}